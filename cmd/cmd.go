@@ -10,6 +10,12 @@ Valid cmd:
 	run_build
 	run_test
 	run_group
+	run_tags
+	run_list
+	rerun
+
+	flaky
+	diff_jobs
 
 	init
 Run 'jarvsim help <command>' for details.
@@ -20,6 +26,7 @@ import (
 	"flag"
 	"fmt"
 	"github.com/shady831213/jarvism/cmd/base"
+	_ "github.com/shady831213/jarvism/cmd/history"
 	_ "github.com/shady831213/jarvism/cmd/init"
 	_ "github.com/shady831213/jarvism/cmd/run"
 	_ "github.com/shady831213/jarvism/cmd/show"
@@ -0,0 +1,126 @@
+package diffJobs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/shady831213/jarvism/cmd"
+	"github.com/shady831213/jarvism/core"
+	"github.com/shady831213/jarvism/core/jobs"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// run jarvism with args and return what it prints
+func runCmd(args ...string) (string, error) {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		out <- buf.String()
+	}()
+	os.Args = append([]string{""}, args...)
+	err = cmd.Run()
+	w.Close()
+	os.Stdout = stdout
+	return <-out, err
+}
+
+func TestDiffJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jarvism_diff_jobs")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("JVS_PRJ_HOME", dir)
+	if err := core.CheckEnv(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	//status of tests in old and new job, "" if the test is not in the job
+	statuses := map[string][2]string{
+		"test1": {"PASS", "FAIL"},
+		"test2": {"FAIL", "PASS"},
+		"test3": {"FAIL", "TIMEOUT"},
+		"test4": {"PASS", ""},
+		"test5": {"", "PASS"},
+		"test6": {"PASS", "PASS"},
+	}
+	paths := make(map[string]string)
+	for i, jobId := range []string{"20190401_0000000000", "20190402_0000000000"} {
+		job := jobs.NewJobRecord(jobId, "group1", nil)
+		for test, status := range statuses {
+			record := &jobs.ResultRecord{Status: status[i], Build: "build1", Groups: []string{"Jarvis"}, Test: test, Seed: 1}
+			paths[test] = record.TestPath()
+			if status[i] != "" {
+				job.Tests = append(job.Tests, record)
+			}
+		}
+		if _, err := jobs.Save(job); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+
+	out, err := runCmd("diff_jobs", "20190401_0000000000", "20190402_0000000000")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for _, section := range []string{"New Failures(1):", "Fixed(1):", "Status Changes(1):", "Added(1):", "Removed(1):", "Runtime Changes(0):"} {
+		if !strings.Contains(out, section) {
+			t.Error("expect " + section + " but get\n" + out)
+			t.FailNow()
+		}
+	}
+	if strings.Contains(out, paths["test6"]) {
+		t.Error("expect unchanged test6 not listed but get\n" + out)
+		t.FailNow()
+	}
+
+	out, err = runCmd("diff_jobs", "20190401_0000000000", "20190402_0000000000", "-threshold", "0.2", "-json")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	diff := new(jobs.JobDiff)
+	if err := json.Unmarshal([]byte(out), diff); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for _, section := range []struct {
+		name    string
+		records []*jobs.DiffRecord
+		test    string
+	}{
+		{"new failures", diff.NewFailures, "test1"},
+		{"fixed", diff.Fixed, "test2"},
+		{"status changes", diff.StatusChanges, "test3"},
+		{"added", diff.Added, "test5"},
+		{"removed", diff.Removed, "test4"},
+	} {
+		status := statuses[section.test]
+		if len(section.records) != 1 || section.records[0].Key != paths[section.test] || section.records[0].OldStatus != status[0] || section.records[0].NewStatus != status[1] {
+			t.Error("expect " + section.test + " " + status[0] + "->" + status[1] + " in " + section.name + " but get\n" + out)
+			t.FailNow()
+		}
+	}
+	if len(diff.RuntimeChanges) != 0 {
+		t.Error("expect no runtime changes but get\n" + out)
+		t.FailNow()
+	}
+
+	if _, err := runCmd("diff_jobs", "20190401_0000000000", "20190403_0000000000"); err == nil {
+		t.Error("expect error of unknown job!")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,119 @@
+package flaky_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/shady831213/jarvism/cmd"
+	"github.com/shady831213/jarvism/core"
+	"github.com/shady831213/jarvism/core/jobs"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// run jarvism with args and return what it prints
+func runCmd(args ...string) (string, error) {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		out <- buf.String()
+	}()
+	os.Args = append([]string{""}, args...)
+	err = cmd.Run()
+	w.Close()
+	os.Stdout = stdout
+	return <-out, err
+}
+
+func TestFlaky(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jarvism_flaky")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("JVS_PRJ_HOME", dir)
+	if err := core.CheckEnv(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	//test1 flips every job, test2 flips once, test3 is stable
+	outcomes := map[string][]string{"test1": {"PASS", "FAIL", "PASS"}, "test2": {"PASS", "FAIL", "FAIL"}, "test3": {"PASS", "PASS", "PASS"}}
+	paths := make(map[string]string)
+	for i := 0; i < 3; i++ {
+		job := jobs.NewJobRecord("20190401_000000000"+strconv.Itoa(i), "group1", nil)
+		for _, test := range []string{"test1", "test2", "test3"} {
+			record := &jobs.ResultRecord{Status: outcomes[test][i], Build: "build1", BuildHash: "hash", Groups: []string{"Jarvis"}, Test: test, Seed: 1}
+			paths[test] = record.TestPath()
+			job.Tests = append(job.Tests, record)
+		}
+		if _, err := jobs.Save(job); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+
+	out, err := runCmd("flaky", "-n", "3")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if strings.Contains(out, paths["test3"]) {
+		t.Error("expect stable test3 not listed but get\n" + out)
+		t.FailNow()
+	}
+	if i1, i2 := strings.Index(out, paths["test1"]), strings.Index(out, paths["test2"]); i1 < 0 || i2 < 0 || i1 > i2 {
+		t.Error("expect test1 listed before test2 but get\n" + out)
+		t.FailNow()
+	}
+
+	out, err = runCmd("flaky", "-json")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	report := new(jobs.FlakyReport)
+	if err := json.Unmarshal([]byte(out), report); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(report.Jobs) != 3 {
+		t.Error("expect 3 jobs but get " + strconv.Itoa(len(report.Jobs)) + "!")
+		t.FailNow()
+	}
+	expects := []struct {
+		path         string
+		flips, fails int
+		score        float64
+	}{{paths["test1"], 2, 1, 1}, {paths["test2"], 1, 2, 0.5}}
+	if len(report.Tests) != len(expects) {
+		t.Error("expect " + strconv.Itoa(len(expects)) + " flaky tests but get\n" + out)
+		t.FailNow()
+	}
+	for i, expect := range expects {
+		test := report.Tests[i]
+		if test.Key != expect.path || test.Flips != expect.flips || test.Fails != expect.fails || test.Runs != 3 || test.Score != expect.score {
+			t.Error("expect " + expect.path + " at " + strconv.Itoa(i) + " with score " + strconv.FormatFloat(expect.score, 'f', 2, 64) + " but get\n" + out)
+			t.FailNow()
+		}
+	}
+	if len(report.Groups) != 1 || report.Groups[0].Group != "Jarvis" || report.Groups[0].Tests != 3 || report.Groups[0].FlakyTests != 2 || report.Groups[0].Score != 0.5 {
+		t.Error("expect group Jarvis with score 0.50 but get\n" + out)
+		t.FailNow()
+	}
+
+	if _, err := runCmd("flaky", "-n", "1"); err == nil {
+		t.Error("expect error with -n 1!")
+		t.FailNow()
+	}
+}
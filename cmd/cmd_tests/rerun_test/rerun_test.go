@@ -0,0 +1,71 @@
+package rerun_test
+
+import (
+	"github.com/shady831213/jarvism/cmd"
+	"github.com/shady831213/jarvism/core"
+	"github.com/shady831213/jarvism/core/jobs"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+func latestJob(t *testing.T) *jobs.JobRecord {
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(records) != 1 {
+		t.Error("no job found!")
+		t.FailNow()
+	}
+	return records[0]
+}
+
+func hasArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRerun(t *testing.T) {
+	if err := core.CheckEnv(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	job := jobs.NewJobRecord("20190401_0000000000", "test1", []string{"-repeat 4"})
+	for seed, status := range []string{"PASS", "FAIL", "FAIL", "UNKNOWN"} {
+		test := &jobs.ResultRecord{Status: status, Build: "build1", Test: "test1", Seed: seed + 1, Args: []string{"-repeat 4"}}
+		job.Tests = append(job.Tests, test)
+	}
+	if _, err := jobs.Save(job); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	os.Args = []string{"", "rerun", job.JobId, "-status", "fail,unknown", "-wave"}
+	if err := cmd.Run(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	rerunJob := latestJob(t)
+	if rerunJob.JobId == job.JobId || len(rerunJob.Tests) != 3 {
+		t.Error("expect a new job with 3 tests!")
+		t.FailNow()
+	}
+	for _, test := range rerunJob.Tests {
+		if test.Build != "build1" || test.Test != "test1" || test.Seed == 1 || !hasArg(test.Args, "-wave") {
+			t.Error("unexpected rerun test", test.Key())
+		}
+	}
+	os.RemoveAll(core.GetWorkDir())
+}
+
+func init() {
+	abs, _ := filepath.Abs(path.Join(core.PkgPath(), "cmd", "cmd_tests", "rerun_test", "testFiles"))
+	os.Setenv("JVS_PRJ_HOME", abs)
+}
@@ -0,0 +1 @@
+module testRunner
\ No newline at end of file
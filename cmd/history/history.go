@@ -0,0 +1,160 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shady831213/jarvism/cmd/base"
+	"github.com/shady831213/jarvism/core"
+	"github.com/shady831213/jarvism/core/jobs"
+	"github.com/shady831213/jarvism/core/utils"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+var CmdFlaky = &base.Command{
+	UsageLine: "jarvism flaky [-n jobs][-json]",
+	Short:     "list tests whose pass/fail outcome changed in recent jobs",
+	Long: `
+-n number of recent jobs to check, default is 10.
+-json print report in json.
+Outcome of a test in a job is failed if any of its seeds failed, retried attempts are ignored.
+Outcomes are compared between consecutive jobs with the same build hash, score is flips/pairs of such jobs, 0 is stable and 1 changes every job.
+SAME_SEED_FLIPS counts flips with the same seed, such test is not deterministic.
+Score of a group is the mean score of its tests.
+Job records are in $JVS_WORK_DIR/jobs.
+`,
+}
+
+var CmdDiffJobs = &base.Command{
+	UsageLine: "jarvism diff_jobs [old_job_id][new_job_id][-threshold ratio][-json]",
+	Short:     "compare two jobs and list new failures, fixed tests, status changes, added/removed tests and runtime changes",
+	Long: `
+-threshold ratio of significant runtime changes, default is 0.5(50%), changes less than 1s are ignored.
+-json print differences in json.
+Runs of all seeds of a test are merged, status of a test is the worst status of its runs, and runtime is the mean of its runs.
+Job records are in $JVS_WORK_DIR/jobs.
+`,
+	CustomFlags: true,
+}
+
+var flakyJobs int
+var flakyJson bool
+var diffThreshold float64
+var diffJson bool
+
+func init() {
+	CmdFlaky.Flag.IntVar(&flakyJobs, "n", jobs.DefaultFlakyWindow, "number of recent jobs to check")
+	CmdFlaky.Flag.BoolVar(&flakyJson, "json", false, "print report in json")
+	CmdFlaky.Run = runFlaky
+	CmdDiffJobs.Flag.Float64Var(&diffThreshold, "threshold", 0.5, "ratio of significant runtime changes")
+	CmdDiffJobs.Flag.BoolVar(&diffJson, "json", false, "print differences in json")
+	CmdDiffJobs.Run = runDiffJobs
+	base.Jarvism.AddCommand(CmdFlaky, CmdDiffJobs)
+}
+
+func printJson(v interface{}) error {
+	bytes, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(bytes))
+	return nil
+}
+
+func runFlaky(cmd *base.Command, args []string) error {
+	if err := core.CheckEnv(); err != nil {
+		return err
+	}
+	if flakyJobs < 2 {
+		return errors.New(utils.Red("jarvism flaky needs at least 2 jobs but get -n " + strconv.Itoa(flakyJobs) + "!"))
+	}
+	records, err := jobs.Latest(flakyJobs)
+	if err != nil {
+		return err
+	}
+	report := jobs.Flaky(records)
+	if flakyJson {
+		return printJson(report)
+	}
+	const padding = 3
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, padding, ' ', tabwriter.DiscardEmptyColumns|tabwriter.TabIndent|tabwriter.Debug)
+	fmt.Fprintln(w, "Flaky Tests in last "+strconv.Itoa(len(report.Jobs))+" jobs:")
+	fmt.Fprintln(w, "SCORE\tFLIPS\tSAME_SEED_FLIPS\tRUNS\tFAILS\tTEST\t")
+	for _, test := range report.Tests {
+		fmt.Fprintln(w, fmt.Sprintf("%.2f", test.Score)+"\t"+strconv.Itoa(test.Flips)+"\t"+strconv.Itoa(test.SameSeedFlips)+"\t"+strconv.Itoa(test.Runs)+"\t"+strconv.Itoa(test.Fails)+"\t"+test.Key+"\t")
+	}
+	w.Flush()
+	fmt.Fprintln(w, "Flaky Groups in last "+strconv.Itoa(len(report.Jobs))+" jobs:")
+	fmt.Fprintln(w, "SCORE\tFLAKY_TESTS\tTESTS\tGROUP\t")
+	for _, group := range report.Groups {
+		fmt.Fprintln(w, fmt.Sprintf("%.2f", group.Score)+"\t"+strconv.Itoa(group.FlakyTests)+"\t"+strconv.Itoa(group.Tests)+"\t"+group.Group+"\t")
+	}
+	return w.Flush()
+}
+
+//"-" if the test is not in the job
+func fmtDiff(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func fmtDiffTime(t float64) string {
+	if t == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fs", t)
+}
+
+func runDiffJobs(cmd *base.Command, args []string) error {
+	if len(args) < 2 || base.IsArg(args[0]) || base.IsArg(args[1]) || base.IsHelp(args[0]) {
+		cmd.Flag.Usage()
+		return errors.New(utils.Red("jarvism diff_jobs must assign old_job_id and new_job_id"))
+	}
+	if err := cmd.Flag.Parse(args[2:]); err != nil {
+		return err
+	}
+	if err := core.CheckEnv(); err != nil {
+		return err
+	}
+	oldJob, err := jobs.Load(args[0])
+	if err != nil {
+		return err
+	}
+	newJob, err := jobs.Load(args[1])
+	if err != nil {
+		return err
+	}
+	diff := jobs.Diff(oldJob, newJob, diffThreshold)
+	if diffJson {
+		return printJson(diff)
+	}
+	const padding = 3
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, padding, ' ', tabwriter.DiscardEmptyColumns|tabwriter.TabIndent|tabwriter.Debug)
+	fmt.Fprintln(w, "Differences from job "+diff.OldJob+" to job "+diff.NewJob+":")
+	for _, section := range []struct {
+		name    string
+		records []*jobs.DiffRecord
+	}{
+		{"New Failures", diff.NewFailures},
+		{"Fixed", diff.Fixed},
+		{"Status Changes", diff.StatusChanges},
+		{"Added", diff.Added},
+		{"Removed", diff.Removed},
+		{"Runtime Changes", diff.RuntimeChanges},
+	} {
+		fmt.Fprintln(w, section.name+"("+strconv.Itoa(len(section.records))+"):")
+		if len(section.records) == 0 {
+			continue
+		}
+		fmt.Fprintln(w, "OLD\tNEW\tOLD_FAILS\tNEW_FAILS\tOLD_TIME\tNEW_TIME\tTEST\t")
+		for _, r := range section.records {
+			fmt.Fprintln(w, fmtDiff(r.OldStatus)+"\t"+fmtDiff(r.NewStatus)+"\t"+fmtDiff(r.OldFails)+"\t"+fmtDiff(r.NewFails)+"\t"+fmtDiffTime(r.OldTime)+"\t"+fmtDiffTime(r.NewTime)+"\t"+r.Key+"\t")
+		}
+		w.Flush()
+	}
+	return w.Flush()
+}
@@ -3,6 +3,7 @@ package run
 import (
 	"errors"
 	"github.com/shady831213/jarvism/cmd/base"
+	jvsErrors "github.com/shady831213/jarvism/core/errors"
 	"github.com/shady831213/jarvism/core/options"
 	"github.com/shady831213/jarvism/core/runtime"
 	"github.com/shady831213/jarvism/core/utils"
@@ -50,6 +51,51 @@ Use "jarvsim show_args" for more information about available arguments.
 Use "jarvsim show_builds" for more information about valid builds.
 Use "jarvsim show_tests build_name" for more information about valid tests list for a build.
 Use "jarvsim show_groups" for more information about valid groups.
+Use "-include regexp" and "-exclude regexp" to filter tests by "build__group1__group2__test" path.
+Use "-tags tag_expr" to filter tests by tags, e.g. -tags "smoke && !long".
+`,
+	Flag:        *options.GetJvsOptions(),
+	CustomFlags: true,
+}
+
+var CmdRunTags = &base.Command{
+	UsageLine: "jarvism run_tags [tag_expr][args]",
+	Short:     "run tests of all groups whose tags match tag_expr",
+	Long: `
+tag_expr supports "!", "&&", "||" and parentheses, e.g. "smoke && !long", "(ddr || pcie) && !long".
+Tags of a test are the union of its own tags and tags of groups it belongs to.
+Use "jarvsim show_args" for more information about available arguments.
+Use "jarvsim show_tests build_name" for more information about tags of tests for a build.
+`,
+	Flag:        *options.GetJvsOptions(),
+	CustomFlags: true,
+}
+
+var CmdRunList = &base.Command{
+	UsageLine: "jarvism run_list [list_file][args]",
+	Short:     "run tests in list file in one job",
+	Long: `
+Each test in list_file is "build test [seed] [args...]", seed is optional.
+Format is decided by extension of list_file:
+	.yaml/.yml: a list of lines or maps with build, test, seed and args keys
+	.csv: build,test,seed,arg1,arg2..., seed could be empty, the first line is header if it begins with build
+	others: plain text, one test per line, "#" starts a comment
+args are applied to all tests, args of each test override them.
+Use "jarvsim show_args" for more information about available arguments.
+`,
+	Flag:        *options.GetJvsOptions(),
+	CustomFlags: true,
+}
+
+var CmdRerun = &base.Command{
+	UsageLine: "jarvism rerun [job_id][-status status1,status2][args]",
+	Short:     "rerun tests of a previous job with the same build, seed and args",
+	Long: `
+-status select tests by status, default is fail,unknown,timeout.
+args will be appended to args of the previous job, e.g. -wave.
+Args selecting tests of the previous job, e.g. -tags, -include, -exclude, -shard, -sample, -duration, -repeat, -seed and -seeds_file, are dropped.
+Job records are in $JVS_WORK_DIR/jobs.
+Use "jarvsim show_args" for more information about available arguments.
 `,
 	Flag:        *options.GetJvsOptions(),
 	CustomFlags: true,
@@ -60,7 +106,10 @@ func init() {
 	CmdRunTest.Run = runRunTest
 	CmdRunBuild.Run = runRunBuild
 	CmdRunGroup.Run = runRunGroup
-	base.Jarvism.AddCommand(CmdRunParse, CmdRunTest, CmdRunGroup, CmdRunBuild)
+	CmdRunTags.Run = runRunTags
+	CmdRunList.Run = runRunList
+	CmdRerun.Run = runRerun
+	base.Jarvism.AddCommand(CmdRunParse, CmdRunTest, CmdRunGroup, CmdRunTags, CmdRunList, CmdRunBuild, CmdRerun)
 }
 
 func formatArgs(args []string) []string {
@@ -81,6 +130,14 @@ func catSignal(sc chan os.Signal) {
 	sc <- <-stopChan
 }
 
+//jarvism exits 1 if any build or test fails, waived tests are not failures
+func exitStatus(err error) error {
+	if err == nil && runtime.Failed() {
+		base.SetExitStatus(1)
+	}
+	return err
+}
+
 func runRunParse(cmd *base.Command, args []string) error {
 	return base.Parse()
 }
@@ -100,7 +157,7 @@ func runRunTest(cmd *base.Command, args []string) error {
 	sc := make(chan os.Signal)
 	defer close(sc)
 	go catSignal(sc)
-	return runtime.RunTest(args[1], args[0], runArgs, sc)
+	return exitStatus(runtime.RunTest(args[1], args[0], runArgs, sc))
 }
 
 func runRunBuild(cmd *base.Command, args []string) error {
@@ -118,7 +175,7 @@ func runRunBuild(cmd *base.Command, args []string) error {
 	sc := make(chan os.Signal)
 	defer close(sc)
 	go catSignal(sc)
-	return runtime.RunOnlyBuild(args[0], runArgs, sc)
+	return exitStatus(runtime.RunOnlyBuild(args[0], runArgs, sc))
 }
 
 func runRunGroup(cmd *base.Command, args []string) error {
@@ -136,5 +193,83 @@ func runRunGroup(cmd *base.Command, args []string) error {
 	sc := make(chan os.Signal)
 	defer close(sc)
 	go catSignal(sc)
-	return runtime.RunGroup(args[0], runArgs, sc)
+	return exitStatus(runtime.RunGroup(args[0], runArgs, sc))
+}
+
+func runRunTags(cmd *base.Command, args []string) error {
+	if len(args) < 1 || base.IsArg(args[0]) || base.IsHelp(args[0]) {
+		cmd.Flag.Usage()
+		return errors.New(utils.Red("jarvism run_tags must assign tag_expr"))
+	}
+	if err := base.Parse(); err != nil {
+		return err
+	}
+	var runArgs []string
+	if len(args) > 1 {
+		runArgs = formatArgs(args[1:])
+	}
+	sc := make(chan os.Signal)
+	defer close(sc)
+	go catSignal(sc)
+	return exitStatus(runtime.RunTags(args[0], runArgs, sc))
+}
+
+func runRunList(cmd *base.Command, args []string) error {
+	if len(args) < 1 || base.IsArg(args[0]) || base.IsHelp(args[0]) {
+		cmd.Flag.Usage()
+		return errors.New(utils.Red("jarvism run_list must assign list_file"))
+	}
+	if err := base.Parse(); err != nil {
+		return err
+	}
+	var runArgs []string
+	if len(args) > 1 {
+		runArgs = formatArgs(args[1:])
+	}
+	sc := make(chan os.Signal)
+	defer close(sc)
+	go catSignal(sc)
+	return exitStatus(runtime.RunList(args[0], runArgs, sc))
+}
+
+func parseStatus(s string) ([]jvsErrors.JVSRuntimeStatus, error) {
+	statuses := make([]jvsErrors.JVSRuntimeStatus, 0)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		status := jvsErrors.StatusFromString(name)
+		if jvsErrors.StatusString(status) != name {
+			return nil, errors.New(utils.Red("unknown status " + name + "!"))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func runRerun(cmd *base.Command, args []string) error {
+	if len(args) < 1 || base.IsArg(args[0]) || base.IsHelp(args[0]) {
+		cmd.Flag.Usage()
+		return errors.New(utils.Red("jarvism rerun must assign job_id"))
+	}
+	if err := base.Parse(); err != nil {
+		return err
+	}
+	statuses := []jvsErrors.JVSRuntimeStatus{jvsErrors.JVSRuntimeFail, jvsErrors.JVSRuntimeUnknown, jvsErrors.JVSRuntimeTimeout}
+	runArgs := make([]string, 0)
+	if len(args) > 1 {
+		for _, arg := range formatArgs(args[1:]) {
+			if strings.HasPrefix(arg, "-status ") {
+				s, err := parseStatus(strings.TrimPrefix(arg, "-status "))
+				if err != nil {
+					return err
+				}
+				statuses = s
+				continue
+			}
+			runArgs = append(runArgs, arg)
+		}
+	}
+	sc := make(chan os.Signal)
+	defer close(sc)
+	go catSignal(sc)
+	return exitStatus(runtime.RerunJob(args[0], statuses, runArgs, sc))
 }
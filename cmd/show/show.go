@@ -17,7 +17,7 @@ var CmdShowArgs = &base.Command{
 
 var CmdShowTests = &base.Command{
 	UsageLine: "jarvism show_tests [build_name]",
-	Short:     "list tests in corresponding build",
+	Short:     "list tests and their tags in corresponding build",
 }
 
 var CmdShowBuilds = &base.Command{
@@ -64,6 +64,10 @@ func runShowTests(cmd *base.Command, args []string) error {
 	dis := loader.GetJvsAstRoot().GetBuild(args[0]).GetTestDiscoverer()
 	fmt.Println("all tests founded by testDiscoverer " + dis.Name() + " of build " + args[0] + ":")
 	for _, t := range dis.TestList() {
+		if tags := loader.GetJvsAstRoot().GetTestTags(args[0], t); len(tags) > 0 {
+			fmt.Println("\t", t, tags)
+			continue
+		}
 		fmt.Println("\t", t)
 	}
 	return nil
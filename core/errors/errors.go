@@ -6,8 +6,11 @@ JVSAst* for Lex, ast parser and Plugin loader
 package errors
 
 import (
+	"encoding/json"
 	"github.com/shady831213/jarvism/core/utils"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type JVSRuntimeStatus int
@@ -18,6 +21,9 @@ const (
 	JVSRuntimeUnknown
 	JVSRuntimeWarning
 	JVSRuntimeFail
+	JVSRuntimeTimeout
+	JVSRuntimeSkipped
+	JVSRuntimeWaived
 )
 
 //render status:
@@ -29,6 +35,12 @@ const (
 //fail red
 //
 //unknown light red
+//
+//timeout purple
+//
+//skipped cyan
+//
+//waived blue
 func StatusColor(status JVSRuntimeStatus) func(str string, modifier ...interface{}) string {
 	switch status {
 	case JVSRuntimePass:
@@ -39,6 +51,12 @@ func StatusColor(status JVSRuntimeStatus) func(str string, modifier ...interface
 		return utils.Red
 	case JVSRuntimeUnknown:
 		return utils.LightRed
+	case JVSRuntimeTimeout:
+		return utils.Purple
+	case JVSRuntimeSkipped:
+		return utils.Cyan
+	case JVSRuntimeWaived:
+		return utils.Blue
 	}
 	return utils.LightRed
 }
@@ -54,10 +72,35 @@ func StatusString(status JVSRuntimeStatus) string {
 		return "FAIL"
 	case JVSRuntimeUnknown:
 		return "UNKNOWN"
+	case JVSRuntimeTimeout:
+		return "TIMEOUT"
+	case JVSRuntimeSkipped:
+		return "SKIPPED"
+	case JVSRuntimeWaived:
+		return "WAIVED"
 	}
 	return "UNKNOWN"
 }
 
+//convert string to status, invert of StatusString
+func StatusFromString(s string) JVSRuntimeStatus {
+	switch s {
+	case "PASS":
+		return JVSRuntimePass
+	case "WARNING":
+		return JVSRuntimeWarning
+	case "FAIL":
+		return JVSRuntimeFail
+	case "TIMEOUT":
+		return JVSRuntimeTimeout
+	case "SKIPPED":
+		return JVSRuntimeSkipped
+	case "WAIVED":
+		return JVSRuntimeWaived
+	}
+	return JVSRuntimeUnknown
+}
+
 //convert status to short string
 func StatusShortString(status JVSRuntimeStatus) string {
 	switch status {
@@ -69,24 +112,48 @@ func StatusShortString(status JVSRuntimeStatus) string {
 		return "F"
 	case JVSRuntimeUnknown:
 		return "U"
+	case JVSRuntimeTimeout:
+		return "TO"
+	case JVSRuntimeSkipped:
+		return "S"
+	case JVSRuntimeWaived:
+		return "WV"
 	}
 	return "U"
 }
 
+//fail, unknown and timeout are failed, waived ones are not
+func IsFailed(status JVSRuntimeStatus) bool {
+	return status == JVSRuntimeFail || status == JVSRuntimeUnknown || status == JVSRuntimeTimeout
+}
+
 //runtime result, for build and test
 //
-//Status: pass, fail, unknown, warning
+//Status: pass, fail, unknown, warning, timeout, skipped, waived
 //
-//title:  "", Error, Unknown, Warning
+//title:  "", Error, Unknown, Warning, Timeout, Skipped, Waived
 //
 //msg: messages
 //
 //Name: build name or test name
+//
+//StartTime, EndTime: wall-clock time of the build or test, set by runtime
+//
+//Attempts: results of failed attempts before the final one of a retried test, in order
+//
+//Phases: prepare, build or run, and check phases of the build or test, set by runtime
+//
+//Identity: identity of the build or test, set by runtime, Name is its string form for display
 type JVSRuntimeResult struct {
-	Status JVSRuntimeStatus
-	title  string
-	msg    []string
-	Name   string
+	Status    JVSRuntimeStatus
+	title     string
+	msg       []string
+	Name      string
+	StartTime time.Time
+	EndTime   time.Time
+	Attempts  []*JVSRuntimeResult
+	Phases    []*JVSRuntimePhase
+	Identity  *JVSRuntimeIdentity
 }
 
 func (e *JVSRuntimeResult) Error() string {
@@ -97,10 +164,133 @@ func (e *JVSRuntimeResult) Error() string {
 	return StatusColor(e.Status)(StatusString(e.Status) + msg)
 }
 
+//Error with durations of phases, e.g. "PASS(prepare 0.102s, run 2.001s, check 0.000s)"
+func (e *JVSRuntimeResult) PhasesError() string {
+	phases := e.PhasesString()
+	if phases == "" {
+		return e.Error()
+	}
+	msg := e.GetMsg()
+	if msg != "" {
+		msg = "\n" + e.title + msg
+	}
+	return StatusColor(e.Status)(StatusString(e.Status) + "(" + phases + ")" + msg)
+}
+
+//wall-clock time of the build or test, 0 if not finished
+func (e *JVSRuntimeResult) Duration() time.Duration {
+	if e.StartTime.IsZero() || e.EndTime.Before(e.StartTime) {
+		return 0
+	}
+	return e.EndTime.Sub(e.StartTime)
+}
+
+//e.g. "prepare 0.102s, run 2.001s, check 0.000s"
+func (e *JVSRuntimeResult) PhasesString() string {
+	phases := make([]string, 0, len(e.Phases))
+	for _, p := range e.Phases {
+		phases = append(phases, p.String())
+	}
+	return strings.Join(phases, ", ")
+}
+
+func (e *JVSRuntimeResult) AddPhase(name string, start, end time.Time) {
+	e.Phases = append(e.Phases, &JVSRuntimePhase{name, start, end})
+}
+
+//identity of build or test
+//
+//JobId: id of the job
+//
+//Build, BuildHash: build name in config and hash of build
+//
+//Groups: group path of test, from top to bottom, empty for build
+//
+//Test, Seed: test name in config and seed, empty for build
+type JVSRuntimeIdentity struct {
+	JobId     string
+	Build     string
+	BuildHash string
+	Groups    []string
+	Test      string
+	Seed      int
+}
+
+func (i *JVSRuntimeIdentity) IsTest() bool {
+	return i.Test != ""
+}
+
+//name of build with hash, builds with the same hash share the same build dir
+func (i *JVSRuntimeIdentity) BuildName() string {
+	if i.BuildHash == "" {
+		return i.Build
+	}
+	return i.Build + "_" + i.BuildHash
+}
+
+//keys are json arrays of fields, so names containing any separator never collide
+func identityKey(fields ...interface{}) string {
+	b, _ := json.Marshal(fields)
+	return string(b)
+}
+
+//identity of test independent of job and build hash, for maps and sorting, not for display
+func (i *JVSRuntimeIdentity) Key() string {
+	return identityKey(i.Build, i.Groups, i.Test, i.Seed)
+}
+
+//identity of test independent of job, build hash and seed, for maps and sorting, not for display
+func (i *JVSRuntimeIdentity) TestKey() string {
+	return identityKey(i.Build, i.Groups, i.Test)
+}
+
+//path of test for display and matching by regexp, "build__group1__group2__test__seed"
+func (i *JVSRuntimeIdentity) Path() string {
+	return i.TestPath() + "__" + strconv.Itoa(i.Seed)
+}
+
+//path of test without seed, "build__group1__group2__test"
+func (i *JVSRuntimeIdentity) TestPath() string {
+	return strings.Join(append(append([]string{i.Build}, i.Groups...), i.Test), "__")
+}
+
+//string form for display, "jobId__build_hash" for build, "jobId__build_hash__group1__group2__test__seed" for test
+func (i *JVSRuntimeIdentity) String() string {
+	s := []string{i.JobId, i.BuildName()}
+	if !i.IsTest() {
+		return strings.Join(s, "__")
+	}
+	return strings.Join(append(append(s, i.Groups...), i.Test, strconv.Itoa(i.Seed)), "__")
+}
+
+//phase of build or test
+//
+//Name: prepare, build, run or check
+type JVSRuntimePhase struct {
+	Name      string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+func (p *JVSRuntimePhase) Duration() time.Duration {
+	if p.EndTime.Before(p.StartTime) {
+		return 0
+	}
+	return p.EndTime.Sub(p.StartTime)
+}
+
+func (p *JVSRuntimePhase) String() string {
+	return p.Name + " " + strconv.FormatFloat(p.Duration().Seconds(), 'f', 3, 64) + "s"
+}
+
 func (e *JVSRuntimeResult) GetMsg() string {
 	return strings.Join(e.msg, "\n")
 }
 
+func (e *JVSRuntimeResult) GetMsgs() []string {
+	return e.msg
+}
+
 func (e *JVSRuntimeResult) addMsgs(msgs ...string) {
 	for _, msg := range msgs {
 		if strings.Replace(strings.Replace(msg, " ", "", -1), "\n", "", -1) != "" {
@@ -120,54 +310,71 @@ func NewJVSRuntimeResult(status JVSRuntimeStatus, msgs ...string) *JVSRuntimeRes
 		return JVSRuntimeResultFail(msgs...)
 	case JVSRuntimeUnknown:
 		return JVSRuntimeResultUnknown(msgs...)
+	case JVSRuntimeTimeout:
+		return JVSRuntimeResultTimeout(msgs...)
+	case JVSRuntimeSkipped:
+		return JVSRuntimeResultSkipped(msgs...)
+	case JVSRuntimeWaived:
+		return JVSRuntimeResultWaived(msgs...)
 	}
 	return JVSRuntimeResultUnknown(msgs...)
 }
 
 //create pass runtime result
 func JVSRuntimeResultPass(msgs ...string) *JVSRuntimeResult {
-	inst := &JVSRuntimeResult{
-		JVSRuntimePass,
-		"",
-		make([]string, 0),
-		"",
-	}
+	inst := &JVSRuntimeResult{Status: JVSRuntimePass, title: "", msg: make([]string, 0)}
 	inst.addMsgs(msgs...)
 	return inst
 }
 
 //create fail runtime result
 func JVSRuntimeResultFail(msgs ...string) *JVSRuntimeResult {
-	inst := &JVSRuntimeResult{
-		JVSRuntimeFail,
-		"Error:",
-		make([]string, 0),
-		"",
-	}
+	inst := &JVSRuntimeResult{Status: JVSRuntimeFail, title: "Error:", msg: make([]string, 0)}
 	inst.addMsgs(msgs...)
 	return inst
 }
 
 //create waring runtime result
 func JVSRuntimeResultWarning(msgs ...string) *JVSRuntimeResult {
-	inst := &JVSRuntimeResult{
-		JVSRuntimeWarning,
-		"Warning:",
-		make([]string, 0),
-		"",
-	}
+	inst := &JVSRuntimeResult{Status: JVSRuntimeWarning, title: "Warning:", msg: make([]string, 0)}
 	inst.addMsgs(msgs...)
 	return inst
 }
 
 //create unknown runtime result
 func JVSRuntimeResultUnknown(msgs ...string) *JVSRuntimeResult {
-	inst := &JVSRuntimeResult{
-		JVSRuntimeUnknown,
-		"Unknown:",
-		make([]string, 0),
-		"",
-	}
+	inst := &JVSRuntimeResult{Status: JVSRuntimeUnknown, title: "Unknown:", msg: make([]string, 0)}
+	inst.addMsgs(msgs...)
+	return inst
+}
+
+//create timeout runtime result
+func JVSRuntimeResultTimeout(msgs ...string) *JVSRuntimeResult {
+	inst := &JVSRuntimeResult{Status: JVSRuntimeTimeout, title: "Timeout:", msg: make([]string, 0)}
+	inst.addMsgs(msgs...)
+	return inst
+}
+
+//reasons of skipped builds and tests
+const (
+	//tests whose build doesn't pass
+	JVSRuntimeSkipBuildFailed = "build failed"
+	//builds and tests not run when job is stopped
+	JVSRuntimeSkipCancelled = "job is cancelled"
+	//tests filtered by -include, -exclude and -tags
+	JVSRuntimeSkipFiltered = "filtered"
+)
+
+//create skipped runtime result, for builds and tests never run, msgs start with reason
+func JVSRuntimeResultSkipped(msgs ...string) *JVSRuntimeResult {
+	inst := &JVSRuntimeResult{Status: JVSRuntimeSkipped, title: "Skipped:", msg: make([]string, 0)}
+	inst.addMsgs(msgs...)
+	return inst
+}
+
+//create waived runtime result, for failed tests matching a waiver, msgs start with waiver
+func JVSRuntimeResultWaived(msgs ...string) *JVSRuntimeResult {
+	inst := &JVSRuntimeResult{Status: JVSRuntimeWaived, title: "Waived:", msg: make([]string, 0)}
 	inst.addMsgs(msgs...)
 	return inst
 }
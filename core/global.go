@@ -134,3 +134,7 @@ func GetWorkDir() string {
 func GetReportDir() string {
 	return path.Join(GetWorkDir(), "report")
 }
+
+func GetJobsDir() string {
+	return path.Join(GetWorkDir(), "jobs")
+}
@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	jvsErrors "github.com/shady831213/jarvism/core/errors"
+	"math"
+	"sort"
+	"strconv"
+)
+
+//runtime changes less than this are never significant
+const DiffMinRuntimeChange = 1.0
+
+//a test in two jobs, runs of all seeds are merged because seeds are usually different in two regressions
+//
+//Key: "build__group1__group2__test" for display
+//
+//OldStatus, NewStatus: the worst status of all runs, empty if the test is not in the job
+//
+//OldFails, NewFails: failed runs of all runs
+//
+//OldTime, NewTime: mean runtime of runs in seconds
+type DiffRecord struct {
+	Key       string  `json:"key"`
+	OldStatus string  `json:"old_status,omitempty"`
+	NewStatus string  `json:"new_status,omitempty"`
+	OldFails  string  `json:"old_fails,omitempty"`
+	NewFails  string  `json:"new_fails,omitempty"`
+	OldTime   float64 `json:"old_time,omitempty"`
+	NewTime   float64 `json:"new_time,omitempty"`
+}
+
+//differences of tests between two jobs
+//
+//NewFailures: failed in new job but not in old job
+//
+//Fixed: failed in old job but passed in new job
+//
+//StatusChanges: other status changes, e.g. FAIL to TIMEOUT, PASS to SKIPPED
+//
+//Added, Removed: tests only in new job or old job
+//
+//RuntimeChanges: mean runtime changes more than threshold, e.g. 0.5 is 50%
+type JobDiff struct {
+	OldJob         string        `json:"old_job"`
+	NewJob         string        `json:"new_job"`
+	NewFailures    []*DiffRecord `json:"new_failures"`
+	Fixed          []*DiffRecord `json:"fixed"`
+	StatusChanges  []*DiffRecord `json:"status_changes"`
+	Added          []*DiffRecord `json:"added"`
+	Removed        []*DiffRecord `json:"removed"`
+	RuntimeChanges []*DiffRecord `json:"runtime_changes"`
+}
+
+//the worst status wins when runs are merged
+func statusRank(status jvsErrors.JVSRuntimeStatus) int {
+	switch status {
+	case jvsErrors.JVSRuntimeSkipped:
+		return 0
+	case jvsErrors.JVSRuntimePass:
+		return 1
+	case jvsErrors.JVSRuntimeWarning:
+		return 2
+	case jvsErrors.JVSRuntimeWaived:
+		return 3
+	case jvsErrors.JVSRuntimeUnknown:
+		return 4
+	case jvsErrors.JVSRuntimeFail:
+		return 5
+	}
+	return 6
+}
+
+type diffTest struct {
+	path        string
+	status      jvsErrors.JVSRuntimeStatus
+	runs, fails int
+	timed       int
+	time        float64
+}
+
+func (t *diffTest) meanTime() float64 {
+	if t.timed == 0 {
+		return 0
+	}
+	return t.time / float64(t.timed)
+}
+
+//interrupted results are ignored
+func diffTests(record *JobRecord) map[string]*diffTest {
+	tests := make(map[string]*diffTest)
+	for _, test := range record.Tests {
+		if test.Interrupted {
+			continue
+		}
+		key := test.TestKey()
+		t, ok := tests[key]
+		if !ok {
+			t = &diffTest{path: test.TestPath(), status: jvsErrors.JVSRuntimeSkipped}
+			tests[key] = t
+		}
+		status := test.GetStatus()
+		if statusRank(status) > statusRank(t.status) {
+			t.status = status
+		}
+		if status == jvsErrors.JVSRuntimeSkipped {
+			continue
+		}
+		t.runs++
+		if jvsErrors.IsFailed(status) {
+			t.fails++
+		}
+		if !test.StartTime.IsZero() && test.EndTime.After(test.StartTime) {
+			t.timed++
+			t.time += test.EndTime.Sub(test.StartTime).Seconds()
+		}
+	}
+	return tests
+}
+
+func sortDiffRecords(records []*DiffRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Key < records[j].Key
+	})
+}
+
+//compare tests of new job with old job, runtime changes more than threshold and DiffMinRuntimeChange seconds are significant
+func Diff(oldJob, newJob *JobRecord, threshold float64) *JobDiff {
+	diff := &JobDiff{oldJob.JobId, newJob.JobId, make([]*DiffRecord, 0), make([]*DiffRecord, 0), make([]*DiffRecord, 0), make([]*DiffRecord, 0), make([]*DiffRecord, 0), make([]*DiffRecord, 0)}
+	oldTests, newTests := diffTests(oldJob), diffTests(newJob)
+	for key, o := range oldTests {
+		record := &DiffRecord{Key: o.path, OldStatus: jvsErrors.StatusString(o.status), OldFails: strconv.Itoa(o.fails) + "/" + strconv.Itoa(o.runs), OldTime: o.meanTime()}
+		n, ok := newTests[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, record)
+			continue
+		}
+		record.NewStatus, record.NewFails, record.NewTime = jvsErrors.StatusString(n.status), strconv.Itoa(n.fails)+"/"+strconv.Itoa(n.runs), n.meanTime()
+		switch {
+		case !jvsErrors.IsFailed(o.status) && jvsErrors.IsFailed(n.status):
+			diff.NewFailures = append(diff.NewFailures, record)
+		case jvsErrors.IsFailed(o.status) && (n.status == jvsErrors.JVSRuntimePass || n.status == jvsErrors.JVSRuntimeWarning):
+			diff.Fixed = append(diff.Fixed, record)
+		case o.status != n.status:
+			diff.StatusChanges = append(diff.StatusChanges, record)
+		}
+		if record.OldTime > 0 && record.NewTime > 0 {
+			change := math.Abs(record.NewTime - record.OldTime)
+			if change >= DiffMinRuntimeChange && change >= threshold*record.OldTime {
+				diff.RuntimeChanges = append(diff.RuntimeChanges, record)
+			}
+		}
+	}
+	for key, n := range newTests {
+		if _, ok := oldTests[key]; !ok {
+			diff.Added = append(diff.Added, &DiffRecord{Key: n.path, NewStatus: jvsErrors.StatusString(n.status), NewFails: strconv.Itoa(n.fails) + "/" + strconv.Itoa(n.runs), NewTime: n.meanTime()})
+		}
+	}
+	for _, records := range [][]*DiffRecord{diff.NewFailures, diff.Fixed, diff.StatusChanges, diff.Added, diff.Removed, diff.RuntimeChanges} {
+		sortDiffRecords(records)
+	}
+	return diff
+}
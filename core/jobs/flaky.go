@@ -0,0 +1,191 @@
+package jobs
+
+import (
+	jvsErrors "github.com/shady831213/jarvism/core/errors"
+	"sort"
+)
+
+//number of recent jobs to detect flaky tests by default
+const DefaultFlakyWindow = 10
+
+//test whose outcome changed across jobs
+//
+//Key: "build__group1__group2__test" for display, seed is not included
+//
+//Runs: jobs in which the test passed or failed, results of all seeds in a job are merged, and it is failed if any of them failed.
+//Retried attempts are not results, waived tests are failed
+//
+//Flips: outcome changes between consecutive jobs with the same build hash, across seeds
+//
+//SameSeedFlips: outcome changes of the same seed between consecutive jobs with the same build hash, the test is not deterministic
+//
+//Score: Flips/pairs, pairs are consecutive jobs with the same build hash, 0 is stable and 1 changes every job
+type FlakyRecord struct {
+	Key           string   `json:"key"`
+	Groups        []string `json:"groups,omitempty"`
+	Runs          int      `json:"runs"`
+	Fails         int      `json:"fails"`
+	Flips         int      `json:"flips"`
+	SameSeedFlips int      `json:"same_seed_flips"`
+	Score         float64  `json:"score"`
+	testKey       string
+}
+
+//the same as ResultRecord.TestKey of its results
+func (r *FlakyRecord) TestKey() string {
+	return r.testKey
+}
+
+//Tests: tests of the group in all jobs
+//
+//FlakyTests: tests of the group which flipped
+//
+//Score: mean score of tests of the group
+type FlakyGroupRecord struct {
+	Group      string  `json:"group"`
+	Tests      int     `json:"tests"`
+	FlakyTests int     `json:"flaky_tests"`
+	Score      float64 `json:"score"`
+}
+
+//flaky tests and groups found in jobs, the flakiest first
+type FlakyReport struct {
+	Jobs   []string            `json:"jobs"`
+	Tests  []*FlakyRecord      `json:"tests"`
+	Groups []*FlakyGroupRecord `json:"groups"`
+}
+
+//pass and warning are passed, fail, unknown, timeout and waived are failed, skipped is not an outcome
+func outcome(r *ResultRecord) (passed bool, ok bool) {
+	status := r.GetStatus()
+	switch {
+	case status == jvsErrors.JVSRuntimePass || status == jvsErrors.JVSRuntimeWarning:
+		return true, true
+	case jvsErrors.IsFailed(status) || status == jvsErrors.JVSRuntimeWaived:
+		return false, true
+	}
+	return false, false
+}
+
+//run of a test in a job, seed is 0 if all seeds are merged
+type flakyRun struct {
+	hash string
+	seed int
+}
+
+//outcomes of a test in a job, failed if any result failed
+func mergeOutcome(outcomes map[flakyRun]bool, run flakyRun, passed bool) {
+	if last, ok := outcomes[run]; ok {
+		passed = passed && last
+	}
+	outcomes[run] = passed
+}
+
+//records must be in order of time, interrupted results are ignored.
+//Outcomes are only compared across jobs, retried attempts and seeds in one job are never compared.
+func Flaky(records []*JobRecord) *FlakyReport {
+	report := &FlakyReport{make([]string, 0), make([]*FlakyRecord, 0), make([]*FlakyGroupRecord, 0)}
+	//outcomes of each job in order
+	runs := make(map[string][]map[flakyRun]bool)
+	seedRuns := make(map[string][]map[flakyRun]bool)
+	tests := make(map[string]*FlakyRecord)
+	for _, record := range records {
+		report.Jobs = append(report.Jobs, record.JobId)
+		jobRuns := make(map[string]map[flakyRun]bool)
+		jobSeedRuns := make(map[string]map[flakyRun]bool)
+		for _, test := range record.Tests {
+			if test.Interrupted {
+				continue
+			}
+			key := test.TestKey()
+			if _, ok := tests[key]; !ok {
+				tests[key] = &FlakyRecord{Key: test.TestPath(), Groups: test.Groups, testKey: key}
+			}
+			passed, ok := outcome(test)
+			if !ok {
+				continue
+			}
+			if _, ok := jobRuns[key]; !ok {
+				jobRuns[key] = make(map[flakyRun]bool)
+				jobSeedRuns[key] = make(map[flakyRun]bool)
+			}
+			mergeOutcome(jobRuns[key], flakyRun{test.BuildHash, 0}, passed)
+			mergeOutcome(jobSeedRuns[key], flakyRun{test.BuildHash, test.Seed}, passed)
+		}
+		for key := range jobRuns {
+			runs[key] = append(runs[key], jobRuns[key])
+			seedRuns[key] = append(seedRuns[key], jobSeedRuns[key])
+		}
+	}
+
+	groups := make(map[string]*FlakyGroupRecord)
+	for key, test := range tests {
+		pairs := 0
+		lastOfHash := make(map[flakyRun]bool)
+		for _, outcomes := range runs[key] {
+			for run, passed := range outcomes {
+				test.Runs++
+				if !passed {
+					test.Fails++
+				}
+				if last, ok := lastOfHash[run]; ok {
+					pairs++
+					if last != passed {
+						test.Flips++
+					}
+				}
+				lastOfHash[run] = passed
+			}
+		}
+		lastOfSeed := make(map[flakyRun]bool)
+		for _, outcomes := range seedRuns[key] {
+			for run, passed := range outcomes {
+				if last, ok := lastOfSeed[run]; ok && last != passed {
+					test.SameSeedFlips++
+				}
+				lastOfSeed[run] = passed
+			}
+		}
+		if pairs > 0 {
+			test.Score = float64(test.Flips) / float64(pairs)
+		}
+		if test.Flips > 0 {
+			report.Tests = append(report.Tests, test)
+		}
+		for _, name := range test.Groups {
+			group, ok := groups[name]
+			if !ok {
+				group = &FlakyGroupRecord{Group: name}
+				groups[name] = group
+			}
+			group.Tests++
+			group.Score += test.Score
+			if test.Flips > 0 {
+				group.FlakyTests++
+			}
+		}
+	}
+	for _, group := range groups {
+		if group.FlakyTests > 0 {
+			group.Score /= float64(group.Tests)
+			report.Groups = append(report.Groups, group)
+		}
+	}
+
+	sort.Slice(report.Tests, func(i, j int) bool {
+		if report.Tests[i].Score != report.Tests[j].Score {
+			return report.Tests[i].Score > report.Tests[j].Score
+		}
+		if report.Tests[i].Flips != report.Tests[j].Flips {
+			return report.Tests[i].Flips > report.Tests[j].Flips
+		}
+		return report.Tests[i].Key < report.Tests[j].Key
+	})
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].Score != report.Groups[j].Score {
+			return report.Groups[i].Score > report.Groups[j].Score
+		}
+		return report.Groups[i].Group < report.Groups[j].Group
+	})
+	return report
+}
@@ -0,0 +1,258 @@
+/*
+persistent job records
+
+Each runtime job is saved as $JVS_WORK_DIR/jobs/$job_id.json when it is done,
+so later commands can query, compare and rerun past jobs without parsing reports or logs.
+*/
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/shady831213/jarvism/core"
+	jvsErrors "github.com/shady831213/jarvism/core/errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//result of a build or a test
+//
+//Build: build name in config
+//
+//BuildHash: hash of build, builds with the same hash share the same compile database
+//
+//Groups: group path of test, from top to bottom
+//
+//Args: args of test in cmdline format
+//
+//LogDir: dir of build or test reported by runner
+//
+//Interrupted: result is collected after job interrupted, it is not a real result
+//
+//Attempts: failed attempts before the final result of a retried test
+//
+//Phases: prepare, build or run, and check phases of the result
+type ResultRecord struct {
+	Name        string          `json:"name"`
+	Status      string          `json:"status"`
+	Msgs        []string        `json:"msgs,omitempty"`
+	Build       string          `json:"build"`
+	BuildHash   string          `json:"build_hash"`
+	Groups      []string        `json:"groups,omitempty"`
+	Test        string          `json:"test,omitempty"`
+	Seed        int             `json:"seed,omitempty"`
+	Args        []string        `json:"args,omitempty"`
+	LogDir      string          `json:"log_dir,omitempty"`
+	Interrupted bool            `json:"interrupted,omitempty"`
+	StartTime   time.Time       `json:"start_time"`
+	EndTime     time.Time       `json:"end_time"`
+	Attempts    []*ResultRecord `json:"attempts,omitempty"`
+	Phases      []*PhaseRecord  `json:"phases,omitempty"`
+}
+
+//phase of a build or a test, Duration is in seconds
+type PhaseRecord struct {
+	Name      string    `json:"name"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Duration  float64   `json:"duration"`
+}
+
+func NewResultRecord(result *jvsErrors.JVSRuntimeResult) *ResultRecord {
+	inst := new(ResultRecord)
+	inst.Name = result.Name
+	inst.Status = jvsErrors.StatusString(result.Status)
+	inst.Msgs = result.GetMsgs()
+	inst.StartTime = result.StartTime
+	inst.EndTime = result.EndTime
+	if id := result.Identity; id != nil {
+		inst.Build = id.Build
+		inst.BuildHash = id.BuildHash
+		inst.Groups = id.Groups
+		inst.Test = id.Test
+		inst.Seed = id.Seed
+	}
+	for _, attempt := range result.Attempts {
+		inst.Attempts = append(inst.Attempts, NewResultRecord(attempt))
+	}
+	for _, phase := range result.Phases {
+		inst.Phases = append(inst.Phases, &PhaseRecord{phase.Name, phase.StartTime, phase.EndTime, phase.Duration().Seconds()})
+	}
+	return inst
+}
+
+func (r *ResultRecord) GetStatus() jvsErrors.JVSRuntimeStatus {
+	return jvsErrors.StatusFromString(r.Status)
+}
+
+//convert back to runtime result
+func (r *ResultRecord) Result() *jvsErrors.JVSRuntimeResult {
+	result := jvsErrors.NewJVSRuntimeResult(r.GetStatus(), r.Msgs...)
+	result.Name = r.Name
+	result.StartTime = r.StartTime
+	result.EndTime = r.EndTime
+	result.Identity = r.identity()
+	for _, attempt := range r.Attempts {
+		result.Attempts = append(result.Attempts, attempt.Result())
+	}
+	for _, phase := range r.Phases {
+		result.AddPhase(phase.Name, phase.StartTime, phase.EndTime)
+	}
+	return result
+}
+
+func (r *ResultRecord) identity() *jvsErrors.JVSRuntimeIdentity {
+	return &jvsErrors.JVSRuntimeIdentity{Build: r.Build, BuildHash: r.BuildHash, Groups: r.Groups, Test: r.Test, Seed: r.Seed}
+}
+
+//identity of test independent of job, for maps and sorting, see JVSRuntimeIdentity.Key
+func (r *ResultRecord) Key() string {
+	return r.identity().Key()
+}
+
+//identity of test independent of job and seed, for maps and sorting, see JVSRuntimeIdentity.TestKey
+func (r *ResultRecord) TestKey() string {
+	return r.identity().TestKey()
+}
+
+//key of the test in plan of job, the same as Key unless it is retried with a new seed, then it is key of the first attempt
+func (r *ResultRecord) PlanKey() string {
+	if len(r.Attempts) > 0 {
+		return r.Attempts[0].Key()
+	}
+	return r.Key()
+}
+
+//"build__group1__group2__test__seed" for display
+func (r *ResultRecord) Path() string {
+	return r.identity().Path()
+}
+
+//"build__group1__group2__test" for display
+func (r *ResultRecord) TestPath() string {
+	return r.identity().TestPath()
+}
+
+//record of a job
+//
+//Name: group name, test name or build name the job run
+//
+//Args: cmdline args of the job
+//
+//MasterSeed: seeds of all tests are derived from it
+//
+//Shard: "i/n" if job runs the ith of n shards of tests
+//
+//LogFile: jarvism log of the job
+//
+//Plan: all tests planned in the job, without status
+//
+//Interrupted: job is stopped before all tests finish, by signal, -max_fail, -max_fail_rate or -stop_on_build_fail
+//
+//StopReason: why the job is stopped if it is interrupted
+//
+//Signatures: failed tests grouped by failure signature
+type JobRecord struct {
+	JobId       string             `json:"job_id"`
+	Name        string             `json:"name"`
+	Args        []string           `json:"args,omitempty"`
+	MasterSeed  int                `json:"master_seed,omitempty"`
+	Shard       string             `json:"shard,omitempty"`
+	LogFile     string             `json:"log_file"`
+	StartTime   time.Time          `json:"start_time"`
+	EndTime     time.Time          `json:"end_time"`
+	Interrupted bool               `json:"interrupted,omitempty"`
+	StopReason  string             `json:"stop_reason,omitempty"`
+	Builds      []*ResultRecord    `json:"builds"`
+	Tests       []*ResultRecord    `json:"tests"`
+	Plan        []*ResultRecord    `json:"plan"`
+	Signatures  []*SignatureRecord `json:"signatures,omitempty"`
+}
+
+func NewJobRecord(jobId, name string, args []string) *JobRecord {
+	inst := new(JobRecord)
+	inst.JobId = jobId
+	inst.Name = name
+	inst.Args = args
+	inst.StartTime = time.Now()
+	inst.Builds = make([]*ResultRecord, 0)
+	inst.Tests = make([]*ResultRecord, 0)
+	inst.Plan = make([]*ResultRecord, 0)
+	return inst
+}
+
+func recordFile(jobId string) string {
+	return path.Join(core.GetJobsDir(), jobId+".json")
+}
+
+//save record to $JVS_WORK_DIR/jobs/$job_id.json, return file path
+func Save(record *JobRecord) (string, error) {
+	if err := os.MkdirAll(core.GetJobsDir(), os.ModePerm); err != nil {
+		return "", err
+	}
+	bytes, err := json.MarshalIndent(record, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	file := recordFile(record.JobId)
+	//write to tmp file then rename, never leave a broken record
+	tmpFile := file + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, bytes, os.ModePerm); err != nil {
+		return "", err
+	}
+	return file, os.Rename(tmpFile, file)
+}
+
+func Load(jobId string) (*JobRecord, error) {
+	bytes, err := ioutil.ReadFile(recordFile(jobId))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("job " + jobId + " not found in " + core.GetJobsDir() + "!")
+		}
+		return nil, err
+	}
+	record := new(JobRecord)
+	if err := json.Unmarshal(bytes, record); err != nil {
+		return nil, errors.New("job " + jobId + " is broken! " + err.Error())
+	}
+	return record, nil
+}
+
+//all job ids in order of time
+func List() ([]string, error) {
+	files, err := filepath.Glob(path.Join(core.GetJobsDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	jobIds := make([]string, 0)
+	for _, f := range files {
+		jobIds = append(jobIds, strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+	sort.Strings(jobIds)
+	return jobIds, nil
+}
+
+//the latest n jobs in order of time, all jobs if n <= 0
+func Latest(n int) ([]*JobRecord, error) {
+	jobIds, err := List()
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 && len(jobIds) > n {
+		jobIds = jobIds[len(jobIds)-n:]
+	}
+	records := make([]*JobRecord, 0)
+	for _, jobId := range jobIds {
+		record, err := Load(jobId)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
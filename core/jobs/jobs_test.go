@@ -0,0 +1,199 @@
+package jobs_test
+
+import (
+	"github.com/shady831213/jarvism/core"
+	"github.com/shady831213/jarvism/core/errors"
+	"github.com/shady831213/jarvism/core/jobs"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jarvism_jobs")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("JVS_PRJ_HOME", dir)
+	if err := core.CheckEnv(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	for _, jobId := range []string{"job2", "job1", "job3"} {
+		record := jobs.NewJobRecord(jobId, "group1", []string{"-seed 1"})
+		result := jobs.NewResultRecord(errors.JVSRuntimeResultFail("fail!"))
+		result.Build = "build1"
+		result.Groups = []string{"Jarvis", "group1"}
+		result.Test = "test1"
+		result.Seed = 1
+		record.Tests = append(record.Tests, result)
+		if _, err := jobs.Save(record); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+
+	records, err := jobs.Latest(2)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(records) != 2 || records[0].JobId != "job2" || records[1].JobId != "job3" {
+		t.Error("expect job2 and job3!")
+		t.FailNow()
+	}
+	test := records[1].Tests[0]
+	if test.GetStatus() != errors.JVSRuntimeFail {
+		t.Error("expect FAIL but get " + test.Status)
+	}
+	if test.Path() != "build1__Jarvis__group1__test1__1" {
+		t.Error("unexpected path " + test.Path())
+	}
+	//the same path but different tests
+	other := &jobs.ResultRecord{Build: "build1", Groups: []string{"Jarvis__group1"}, Test: "test1", Seed: 1}
+	if other.Path() != test.Path() || other.Key() == test.Key() || other.TestKey() == test.TestKey() {
+		t.Error("expect different keys of " + other.Path())
+	}
+
+	if _, err := jobs.Load("job4"); err == nil {
+		t.Error("expect error for job4!")
+	}
+}
+
+func TestTriage(t *testing.T) {
+	for msg, expect := range map[string]string{
+		"UVM_ERROR /a/b/tb.sv(12) @ 100ns: addr 0x1f mismatch, line 20": "UVM_ERROR <path>(<n>) @ <time>: addr <hex> mismatch, line <n>",
+		"Error-[SIM] data 8'hff != 32'hdeadbeef at 12:01:02":            "Error-[SIM] data <hex> != <hex> at <time>",
+		"killed at 2019-04-01 12:00:00!":                                "killed at <time>!",
+		"seed 123456 crc 1a2b3c4d5e wrong":                              "seed <n> crc <hex> wrong",
+	} {
+		if s := jobs.Signature([]string{"path:/work/test1", msg}); s != expect {
+			t.Error("expect signature \"" + expect + "\" but get \"" + s + "\"")
+		}
+	}
+
+	tests := make([]*jobs.ResultRecord, 0)
+	for i, msg := range []string{"Error: timeout at 10ns", "Error: timeout at 20ns", "Error: bad crc", ""} {
+		result := jobs.NewResultRecord(errors.JVSRuntimeResultFail(msg))
+		result.Name = "test" + strconv.Itoa(i+1)
+		tests = append(tests, result)
+	}
+	tests = append(tests, jobs.NewResultRecord(errors.JVSRuntimeResultPass("")))
+	signatures := jobs.Triage(tests)
+	if len(signatures) != 3 || signatures[0].Count != 2 || signatures[0].Signature != "Error: timeout at <time>" {
+		t.Error("expect 3 signatures and the most common one first!")
+		for _, s := range signatures {
+			t.Log(*s)
+		}
+	}
+}
+
+func TestFlaky(t *testing.T) {
+	records := make([]*jobs.JobRecord, 0)
+	//columns are test1~test4, test1 flips with the same seed, test2 flips across seeds, test3 is stable, test4 is rebuilt every job
+	for i, statuses := range [][]string{
+		{"PASS", "PASS", "FAIL", "FAIL"},
+		{"FAIL", "FAIL", "FAIL", "PASS"},
+		{"PASS", "PASS", "FAIL", "SKIPPED"},
+	} {
+		record := jobs.NewJobRecord("job"+strconv.Itoa(i), "group1", nil)
+		for j, status := range statuses {
+			test := &jobs.ResultRecord{Status: status, Build: "build1", BuildHash: "hash", Groups: []string{"Jarvis", "group1"}, Test: "test" + strconv.Itoa(j+1), Seed: 1}
+			if j == 1 {
+				test.Seed = i
+			}
+			if j == 3 {
+				test.BuildHash += strconv.Itoa(i)
+			}
+			record.Tests = append(record.Tests, test)
+		}
+		records = append(records, record)
+	}
+	report := jobs.Flaky(records)
+	if len(report.Jobs) != 3 || len(report.Tests) != 2 {
+		t.Error("expect 2 flaky tests in 3 jobs!")
+		t.FailNow()
+	}
+	test1, test2 := report.Tests[0], report.Tests[1]
+	if test1.Key != "build1__Jarvis__group1__test1" || test1.Flips != 2 || test1.SameSeedFlips != 2 || test1.Score != 1 {
+		t.Error("unexpected flaky test", *test1)
+	}
+	if test2.Key != "build1__Jarvis__group1__test2" || test2.Flips != 2 || test2.SameSeedFlips != 0 || test2.Score != 1 || test2.Fails != 1 {
+		t.Error("unexpected flaky test", *test2)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].FlakyTests != 2 || report.Groups[0].Tests != 4 || report.Groups[0].Score != 0.5 {
+		t.Error("unexpected flaky groups", report.Groups)
+	}
+}
+
+func TestFlakyInJob(t *testing.T) {
+	records := make([]*jobs.JobRecord, 0)
+	//test1 passes after a failed attempt, test2 fails with one of its seeds, in every job
+	for i := 0; i < 3; i++ {
+		record := jobs.NewJobRecord("job"+strconv.Itoa(i), "group1", nil)
+		attempt := &jobs.ResultRecord{Status: "FAIL", Build: "build1", BuildHash: "hash", Groups: []string{"Jarvis"}, Test: "test1", Seed: 1}
+		record.Tests = append(record.Tests, &jobs.ResultRecord{Status: "PASS", Build: "build1", BuildHash: "hash", Groups: []string{"Jarvis"}, Test: "test1", Seed: 1, Attempts: []*jobs.ResultRecord{attempt}})
+		for seed, status := range []string{"PASS", "FAIL", "PASS"} {
+			record.Tests = append(record.Tests, &jobs.ResultRecord{Status: status, Build: "build1", BuildHash: "hash", Groups: []string{"Jarvis"}, Test: "test2", Seed: seed})
+		}
+		records = append(records, record)
+	}
+	if report := jobs.Flaky(records); len(report.Tests) != 0 {
+		t.Error("expect no flaky tests when outcomes only change within jobs!")
+		for _, test := range report.Tests {
+			t.Log(*test)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	start := time.Now()
+	jobOf := func(jobId string, statuses map[string][]string, runtime time.Duration) *jobs.JobRecord {
+		record := jobs.NewJobRecord(jobId, "group1", nil)
+		for test, ss := range statuses {
+			for seed, status := range ss {
+				record.Tests = append(record.Tests, &jobs.ResultRecord{Status: status, Build: "build1", Groups: []string{"Jarvis"}, Test: test, Seed: seed, StartTime: start, EndTime: start.Add(runtime)})
+			}
+		}
+		return record
+	}
+	oldJob := jobOf("job1", map[string][]string{
+		"test1": {"PASS", "PASS"},
+		"test2": {"FAIL", "PASS"},
+		"test3": {"PASS"},
+		"test4": {"UNKNOWN"},
+		"test5": {"PASS"},
+	}, 10*time.Second)
+	newJob := jobOf("job2", map[string][]string{
+		"test1": {"PASS", "TIMEOUT"},
+		"test2": {"PASS", "PASS"},
+		"test3": {"WARNING"},
+		"test4": {"FAIL"},
+		"test6": {"PASS"},
+	}, 20*time.Second)
+	diff := jobs.Diff(oldJob, newJob, 0.5)
+	for name, records := range map[string][]*jobs.DiffRecord{
+		"build1__Jarvis__test1": diff.NewFailures,
+		"build1__Jarvis__test2": diff.Fixed,
+		"build1__Jarvis__test6": diff.Added,
+		"build1__Jarvis__test5": diff.Removed,
+	} {
+		if len(records) != 1 || records[0].Key != name {
+			t.Error("unexpected diff of " + name)
+		}
+	}
+	if len(diff.StatusChanges) != 2 || diff.StatusChanges[0].NewStatus != "WARNING" || diff.StatusChanges[1].OldStatus != "UNKNOWN" {
+		t.Error("expect status changes of test3 and test4!")
+	}
+	if diff.NewFailures[0].NewFails != "1/2" || diff.NewFailures[0].NewTime != 20 {
+		t.Error("unexpected new failure", *diff.NewFailures[0])
+	}
+	if len(diff.RuntimeChanges) != 4 || len(jobs.Diff(oldJob, newJob, 1.5).RuntimeChanges) != 0 {
+		t.Error("expect runtime of 4 tests changes 100%!")
+	}
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	jvsErrors "github.com/shady831213/jarvism/core/errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//failing tests sharing the same signature, they probably have the same root cause
+//
+//Signature: normalized first error line
+//
+//Tests: names of failing tests
+//
+//Example: path of the first failing test
+//
+//LogDir: log dir of the example
+type SignatureRecord struct {
+	Signature string   `json:"signature"`
+	Status    string   `json:"status"`
+	Count     int      `json:"count"`
+	Tests     []string `json:"tests"`
+	Example   string   `json:"example"`
+	LogDir    string   `json:"log_dir,omitempty"`
+}
+
+var errorLinePat = regexp.MustCompile(`(?i)error|fatal|fail|timeout|killed`)
+
+//normalized patterns in order, paths first because they may contain numbers
+var signaturePats = []struct {
+	pat  *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?:[\w.\-~$]*/)+[\w.\-]+`), "<path>"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}(?:[ T]\d{1,2}:\d{2}:\d{2}(?:\.\d+)?)?`), "<time>"},
+	{regexp.MustCompile(`\d{1,2}:\d{2}:\d{2}(?:\.\d+)?`), "<time>"},
+	{regexp.MustCompile(`\b\d+(?:\.\d+)?\s*(?:fs|ps|ns|us|ms|s)\b`), "<time>"},
+	{regexp.MustCompile(`\b0[xX][0-9a-fA-F_]+\b|\b\d*'[hH][0-9a-fA-F_]+\b`), "<hex>"},
+	{regexp.MustCompile(`\b\d+(?:\.\d+)?\b`), "<n>"},
+	{regexp.MustCompile(`\b[0-9a-fA-F]{8,}\b`), "<hex>"},
+	{regexp.MustCompile(`\d+`), "<n>"},
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+//first error line of messages, runner messages like "path:xxx" are ignored.
+//If no line looks like an error, the first line is used.
+func firstErrorLine(msgs []string) string {
+	first := ""
+	for _, msg := range msgs {
+		for _, line := range strings.Split(msg, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "path:") {
+				continue
+			}
+			if errorLinePat.MatchString(line) {
+				return line
+			}
+			if first == "" {
+				first = line
+			}
+		}
+	}
+	return first
+}
+
+//normalize the first error line of messages, times, hex values, paths and numbers like seeds are stripped,
+//e.g. "UVM_ERROR /a/b/tb.sv(12) @ 100ns: addr 0x1f mismatch, line 20" is "UVM_ERROR <path>(<n>) @ <time>: addr <hex> mismatch, line <n>"
+func Signature(msgs []string) string {
+	s := firstErrorLine(msgs)
+	for _, p := range signaturePats {
+		s = p.pat.ReplaceAllString(s, p.repl)
+	}
+	return strings.TrimSpace(s)
+}
+
+//group failed tests by status and signature, the most common first.
+//Interrupted results are not real failures and are ignored.
+func Triage(tests []*ResultRecord) []*SignatureRecord {
+	signatures := make(map[string]*SignatureRecord)
+	for _, test := range tests {
+		if test.Interrupted || !jvsErrors.IsFailed(test.GetStatus()) {
+			continue
+		}
+		signature := Signature(test.Msgs)
+		key := test.Status + "__" + signature
+		record, ok := signatures[key]
+		if !ok {
+			record = &SignatureRecord{Signature: signature, Status: test.Status, Example: test.Path(), LogDir: test.LogDir}
+			signatures[key] = record
+		}
+		record.Count++
+		record.Tests = append(record.Tests, test.Name)
+	}
+	records := make([]*SignatureRecord, 0, len(signatures))
+	for _, record := range signatures {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Count != records[j].Count {
+			return records[i].Count > records[j].Count
+		}
+		if records[i].Status != records[j].Status {
+			return records[i].Status < records[j].Status
+		}
+		return records[i].Signature < records[j].Signature
+	})
+	return records
+}
@@ -5,9 +5,10 @@ import (
 	"github.com/shady831213/jarvism/core/errors"
 	"github.com/shady831213/jarvism/core/plugin"
 	"github.com/shady831213/jarvism/core/utils"
-	"math"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type astParser interface {
@@ -91,6 +92,21 @@ func WithCheckList(handler func([]interface{}) *errors.JVSAstError) func(interfa
 	}
 }
 
+//timeout can be a duration string like "30m", or seconds in int
+func astParseTimeout(item interface{}) (time.Duration, *errors.JVSAstError) {
+	switch v := item.(type) {
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, errors.JVSAstParseError("timeout", err.Error())
+		}
+		return d, nil
+	}
+	return 0, errors.JVSAstParseError("timeout", fmt.Sprintf("expect a duration string or int seconds but get %T!", item))
+}
+
 func astLoadPlugin(pluginType plugin.JVSPluginType, pluginName string) *errors.JVSAstError {
 	if err := plugin.LoadPlugin(pluginType, pluginName); err != nil {
 		errMsg := string(pluginType) + " " + pluginName + " is invalid! valid " + string(pluginType) + "s are [ "
@@ -611,11 +627,14 @@ func (t *astEnv) GetHierString(space int) string {
 //build
 //------------------------
 
+//Identity is set by runtime when build joins a job, Name is its string form
 type AstBuild struct {
 	Name                        string
+	Identity                    errors.JVSRuntimeIdentity
 	compileItems, simItems      *astItems
 	testDiscoverer              *astPlugin
 	compileChecker, testChecker *astPlugin
+	timeout                     time.Duration
 }
 
 func newAstBuild(name string) *AstBuild {
@@ -642,11 +661,18 @@ func (t *AstBuild) PostCompileAction() string {
 	return t.compileItems.postAction
 }
 
+//wall-clock limit of compiling, 0 is unlimited
+func (t *AstBuild) GetTimeout() time.Duration {
+	return t.timeout
+}
+
 func (t *AstBuild) Clone() *AstBuild {
 	inst := newAstBuild(t.Name)
+	inst.Identity = t.Identity
 	inst.testDiscoverer = t.testDiscoverer
 	inst.compileChecker = t.compileChecker
 	inst.testChecker = t.testChecker
+	inst.timeout = t.timeout
 	inst.simItems.cat(t.simItems)
 	inst.compileItems.cat(t.compileItems)
 	return inst
@@ -667,6 +693,7 @@ func (t *AstBuild) KeywordsChecker(s string) (bool, *utils.StringMapSet, string)
 			keywords.AddKey("test_discoverer")
 			keywords.AddKey("compile_checker")
 			keywords.AddKey("test_checker")
+			keywords.AddKey("timeout")
 			if CheckKeyWord(s, keywords) {
 				return true, nil, ""
 			}
@@ -695,6 +722,17 @@ func (t *AstBuild) Parse(cfg map[interface{}]interface{}) *errors.JVSAstError {
 	}
 	t.compileChecker = compileChecker
 
+	if err := CfgToAstItemOptional(cfg, "timeout", func(item interface{}) *errors.JVSAstError {
+		timeout, err := astParseTimeout(item)
+		if err != nil {
+			return err
+		}
+		t.timeout = timeout
+		return nil
+	}); err != nil {
+		return errors.JVSAstParseError("timeout of build "+t.Name, err.Msg)
+	}
+
 	//options
 	if err := t.compileItems.Parse(cfg); err != nil {
 		return errors.JVSAstParseError("build "+t.Name, err.Msg)
@@ -723,6 +761,8 @@ func (t *AstBuild) GetHierString(space int) string {
 
 type RunTimeOpts interface {
 	GetName() string
+	//group path and name, from top to bottom
+	GetPath() []string
 	//bottom-up search
 	GetBuild() *AstBuild
 	SetBuild(*AstBuild)
@@ -735,17 +775,32 @@ type astTestOpts interface {
 	RunTimeOpts
 	SetParent(parent astTestOpts)
 	//bottom-up search
+	GetBuilds() []*AstBuild
 	GetOptionArgs() *utils.StringMapSet
+	GetTimeout() time.Duration
+	GetRetry() *RetryPolicy
+	GetCfgSeeds() []int
+	GetTags() []string
+	GetWeight() int
 }
 
 type astTest struct {
 	Name       string
 	buildName  string
 	build      *AstBuild
+	buildNames []string
+	builds     []*AstBuild
 	optionArgs *utils.StringMapSet
 	args       []string
 	parent     astTestOpts
 	file       string
+	timeout    time.Duration
+	retry      *RetryPolicy
+	cfgSeeds   []int
+	tags       []string
+	weight     int
+	matrix     []*matrixAxis
+	variants   []*matrixVariant
 }
 
 func (t *astTest) init(name string) {
@@ -758,18 +813,31 @@ func (t *astTest) Copy(i *astTest) {
 	t.Name = i.Name
 	t.buildName = i.buildName
 	t.build = i.build
+	t.buildNames = i.buildNames
+	t.builds = i.builds
 	//shared
 	t.optionArgs = i.optionArgs
 	//shared
 	t.args = t.args
 	t.parent = i.parent
+	t.timeout = i.timeout
+	t.retry = i.retry
+	t.cfgSeeds = i.cfgSeeds
+	t.tags = i.tags
+	t.weight = i.weight
+	t.matrix = i.matrix
+	t.variants = i.variants
 }
 
 func (t *astTest) GetName() string {
+	return strings.Join(t.GetPath(), "__")
+}
+
+func (t *astTest) GetPath() []string {
 	if t.parent != nil {
-		return t.parent.GetName() + "__" + t.Name
+		return append(t.parent.GetPath(), t.Name)
 	}
-	return t.Name
+	return []string{t.Name}
 }
 
 func (t *astTest) SetParent(parent astTestOpts) {
@@ -783,23 +851,112 @@ func (t *astTest) GetOptionArgs() *utils.StringMapSet {
 	return t.optionArgs
 }
 
+//args in cmdline format, bottom-up search
+func (t *astTest) GetArgs() []string {
+	args := make([]string, 0)
+	t.GetOptionArgs().Foreach(func(k string, v interface{}) bool {
+		args = append(args, JvsAstOptionToArg(v.(JvsAstOption)))
+		return false
+	})
+	sort.Strings(args)
+	return args
+}
+
 func (t *astTest) GetBuild() *AstBuild {
 	if t.build != nil {
 		return t.build
 	}
+	//ambiguous, tests are expanded by builds
+	if t.builds != nil {
+		return nil
+	}
 	if t.parent != nil {
 		return t.parent.GetBuild()
 	}
 	return nil
 }
 
+//builds the test runs with, bottom-up search
+func (t *astTest) GetBuilds() []*AstBuild {
+	if t.build != nil {
+		return []*AstBuild{t.build}
+	}
+	if t.builds != nil {
+		return t.builds
+	}
+	if t.parent != nil {
+		return t.parent.GetBuilds()
+	}
+	return nil
+}
+
 func (t *astTest) SetBuild(build *AstBuild) {
 	t.build = build
 }
 
+//wall-clock limit of simulation, 0 is unlimited
+func (t *astTest) GetTimeout() time.Duration {
+	if t.timeout > 0 {
+		return t.timeout
+	}
+	if t.parent != nil {
+		return t.parent.GetTimeout()
+	}
+	return 0
+}
+
+//retry policy of failing tests, bottom-up search, nil is no retry
+func (t *astTest) GetRetry() *RetryPolicy {
+	if t.retry != nil {
+		return t.retry
+	}
+	if t.parent != nil {
+		return t.parent.GetRetry()
+	}
+	return nil
+}
+
+//seeds list in config, bottom-up search, nil if not configured
+func (t *astTest) GetCfgSeeds() []int {
+	if t.cfgSeeds != nil {
+		return t.cfgSeeds
+	}
+	if t.parent != nil {
+		return t.parent.GetCfgSeeds()
+	}
+	return nil
+}
+
+//tags of test and all its parents, sorted
+func (t *astTest) GetTags() []string {
+	tags := utils.NewStringMapSet()
+	for _, tag := range t.tags {
+		tags.AddKey(tag)
+	}
+	if t.parent != nil {
+		for _, tag := range t.parent.GetTags() {
+			tags.AddKey(tag)
+		}
+	}
+	keys := tags.Keys()
+	sort.Strings(keys)
+	return keys
+}
+
+//weight of picking the test by -sample, bottom-up search, default is 1
+func (t *astTest) GetWeight() int {
+	if t.weight > 0 {
+		return t.weight
+	}
+	if t.parent != nil {
+		return t.parent.GetWeight()
+	}
+	return 1
+}
+
 func (t *astTest) KeywordsChecker(s string) (bool, *utils.StringMapSet, string) {
 	keywords := utils.NewStringMapSet()
-	keywords.AddKey("build", "args")
+	keywords.AddKey("build", "builds", "args", "timeout", "retry", "seeds", "tags", "weight", "matrix")
 	if !CheckKeyWord(s, keywords) {
 		return false, keywords, "Error in " + t.Name + ":"
 	}
@@ -818,6 +975,22 @@ func (t *astTest) Parse(cfg map[interface{}]interface{}) *errors.JVSAstError {
 	}); err != nil {
 		return errors.JVSAstParseError("build of "+t.Name, err.Msg)
 	}
+	if err := CfgToAstItemOptional(cfg, "builds", WithCheckList(func(item []interface{}) *errors.JVSAstError {
+		if t.buildName != "" {
+			return errors.JVSAstParseError("builds in test or group", "build and builds can't be defined together!")
+		}
+		t.buildNames = make([]string, 0)
+		for _, build := range item {
+			v, ok := build.(string)
+			if !ok {
+				return errors.JVSAstParseError("builds in test or group", fmt.Sprintf("expect a list of string but get %T!", build))
+			}
+			t.buildNames = append(t.buildNames, v)
+		}
+		return nil
+	})); err != nil {
+		return errors.JVSAstParseError("builds of "+t.Name, err.Msg)
+	}
 	if err := CfgToAstItemOptional(cfg, "args", WithCheckList(func(item []interface{}) *errors.JVSAstError {
 		for _, arg := range item {
 			t.args = append(t.args, strings.Split(arg.(string), ",")...)
@@ -826,6 +999,71 @@ func (t *astTest) Parse(cfg map[interface{}]interface{}) *errors.JVSAstError {
 	})); err != nil {
 		return errors.JVSAstParseError("args of "+t.Name, err.Msg)
 	}
+	if err := CfgToAstItemOptional(cfg, "timeout", func(item interface{}) *errors.JVSAstError {
+		timeout, err := astParseTimeout(item)
+		if err != nil {
+			return err
+		}
+		t.timeout = timeout
+		return nil
+	}); err != nil {
+		return errors.JVSAstParseError("timeout of "+t.Name, err.Msg)
+	}
+	if err := CfgToAstItemOptional(cfg, "retry", func(item interface{}) *errors.JVSAstError {
+		retry, err := astParseRetry(item)
+		if err != nil {
+			return err
+		}
+		t.retry = retry
+		return nil
+	}); err != nil {
+		return errors.JVSAstParseError("retry of "+t.Name, err.Msg)
+	}
+	if err := CfgToAstItemOptional(cfg, "seeds", WithCheckList(func(item []interface{}) *errors.JVSAstError {
+		t.cfgSeeds = make([]int, 0)
+		for _, seed := range item {
+			v, ok := seed.(int)
+			if !ok {
+				return errors.JVSAstParseError("seeds", fmt.Sprintf("expect a list of int but get %T!", seed))
+			}
+			t.cfgSeeds = append(t.cfgSeeds, v)
+		}
+		return nil
+	})); err != nil {
+		return errors.JVSAstParseError("seeds of "+t.Name, err.Msg)
+	}
+	if err := CfgToAstItemOptional(cfg, "tags", WithCheckList(func(item []interface{}) *errors.JVSAstError {
+		for _, tag := range item {
+			v, ok := tag.(string)
+			if !ok {
+				return errors.JVSAstParseError("tags", fmt.Sprintf("expect a list of string but get %T!", tag))
+			}
+			t.tags = append(t.tags, v)
+		}
+		return nil
+	})); err != nil {
+		return errors.JVSAstParseError("tags of "+t.Name, err.Msg)
+	}
+	if err := CfgToAstItemOptional(cfg, "weight", func(item interface{}) *errors.JVSAstError {
+		v, ok := item.(int)
+		if !ok || v < 1 {
+			return errors.JVSAstParseError("weight", fmt.Sprintf("expect a positive int but get %v!", item))
+		}
+		t.weight = v
+		return nil
+	}); err != nil {
+		return errors.JVSAstParseError("weight of "+t.Name, err.Msg)
+	}
+	if err := CfgToAstItemOptional(cfg, "matrix", func(item interface{}) *errors.JVSAstError {
+		matrix, err := astParseMatrix(item)
+		if err != nil {
+			return err
+		}
+		t.matrix = matrix
+		return nil
+	}); err != nil {
+		return errors.JVSAstParseError("matrix of "+t.Name, err.Msg)
+	}
 	return nil
 }
 
@@ -836,10 +1074,20 @@ func (t *astTest) Link() *errors.JVSAstError {
 	if t.buildName != "" {
 		build := jvsAstRoot.GetBuild(t.buildName)
 		if build == nil {
-			return errors.JVSAstLinkError(t.Name+"("+t.file+")", "build "+t.buildName+" of "+t.Name+"is undef!")
+			return errors.JVSAstLinkError(t.Name+"("+t.file+")", "build "+t.buildName+" of "+t.Name+" is undef!")
 		}
 		t.build = build
 	}
+	if t.buildNames != nil {
+		t.builds = make([]*AstBuild, 0)
+		for _, name := range t.buildNames {
+			build := jvsAstRoot.GetBuild(name)
+			if build == nil {
+				return errors.JVSAstLinkError(t.Name+"("+t.file+")", "build "+name+" of "+t.Name+" is undef!")
+			}
+			t.builds = append(t.builds, build)
+		}
+	}
 	for _, arg := range t.args {
 		//Options have been all parsed
 		opt, err := GetJvsAstOption(arg)
@@ -849,6 +1097,13 @@ func (t *astTest) Link() *errors.JVSAstError {
 		t.optionArgs.Add(opt.GetName(), opt.Clone())
 
 	}
+	if t.matrix != nil {
+		variants, err := linkMatrix(t.matrix)
+		if err != nil {
+			return errors.JVSAstLinkError("matrix of "+t.Name+"("+t.file+")", err.Error())
+		}
+		t.variants = variants
+	}
 	return nil
 }
 
@@ -873,10 +1128,16 @@ func (t *astTest) GetHierString(space int) string {
 		})
 }
 
+//Identity of flatten testcase, job and build are set by runtime when testcase joins a job
 type AstTestCase struct {
 	astTest
+	Identity errors.JVSRuntimeIdentity
 	simItems *astItems
 	seeds    []int
+	//seed of flatten testcase
+	seed int
+	//the test which flatten testcase comes from
+	origin *AstTestCase
 }
 
 func newAstTestCase(name string) *AstTestCase {
@@ -911,11 +1172,26 @@ func (t *AstTestCase) Clone() *AstTestCase {
 		inst.seeds = make([]int, len(t.seeds))
 		copy(inst.seeds, t.seeds)
 	}
+	inst.seed = t.seed
+	inst.origin = t.origin
+	inst.Identity = t.Identity
 	return inst
 }
 
+//args in cmdline format, flatten testcases return args of the test they come from
+func (t *AstTestCase) GetArgs() []string {
+	if t.origin != nil {
+		return t.origin.GetArgs()
+	}
+	return t.astTest.GetArgs()
+}
+
 func (t *AstTestCase) ParseArgs() {
 	t.build = t.GetBuild().Clone()
+	//seeds in config, -seed and -seeds_file override them
+	if seeds := t.GetCfgSeeds(); seeds != nil {
+		t.SetSeeds(seeds)
+	}
 	//get options sim_options in order
 	t.GetOptionArgs().Foreach(func(k string, v interface{}) bool {
 		if a, ok := v.(JvsAstOptionForTest); ok {
@@ -928,18 +1204,59 @@ func (t *AstTestCase) ParseArgs() {
 	})
 }
 
+//flatten testcases are generated by seeds
+func (t *AstTestCase) SetSeeds(seeds []int) {
+	t.seeds = make([]int, len(seeds))
+	copy(t.seeds, seeds)
+}
+
 func (t *AstTestCase) GetTestCases() []*AstTestCase {
 	if t.seeds == nil {
 		t.seeds = make([]int, 1)
-		t.seeds[0] = jvsRand.Intn(math.MaxInt32)
+		t.seeds[0] = deriveSeed(t.GetName(), 0)
 	}
 	testcases := make([]*AstTestCase, len(t.seeds))
 	for i := range testcases {
-		testcases[i] = newAstTestCase(t.GetName() + "__" + strconv.Itoa(t.seeds[i]))
-		//copy sim_options and set seed
-		testcases[i].simItems.cat(t.GetBuild().simItems)
-		testcases[i].simItems.cat(t.simItems)
-		testcases[i].simItems.option.cat(newAstItem(GetCurSimulator().SeedOption() + strconv.Itoa(t.seeds[i])))
+		testcases[i] = t.newFlattenTestCase(t.seeds[i])
+	}
+	return testcases
+}
+
+func (t *AstTestCase) newFlattenTestCase(seed int) *AstTestCase {
+	inst := newAstTestCase(t.GetName() + "__" + strconv.Itoa(seed))
+	inst.timeout = t.GetTimeout()
+	inst.retry = t.GetRetry()
+	inst.tags = t.GetTags()
+	inst.seed = seed
+	inst.origin = t
+	path := t.GetPath()
+	inst.Identity = errors.JVSRuntimeIdentity{Groups: path[: len(path)-1 : len(path)-1], Test: t.Name, Seed: seed}
+	//copy sim_options and set seed
+	inst.simItems.cat(t.GetBuild().simItems)
+	inst.simItems.cat(t.simItems)
+	inst.simItems.option.cat(newAstItem(GetCurSimulator().SeedOption() + strconv.Itoa(seed)))
+	return inst
+}
+
+//flatten testcase with a new seed for the nth retry, from the same test of t
+func (t *AstTestCase) Reseed(n int) *AstTestCase {
+	if t.origin == nil {
+		return t.Clone()
+	}
+	return t.origin.newFlattenTestCase(deriveSeed(t.origin.GetName()+"__"+strconv.Itoa(t.seed)+"__retry", n))
+}
+
+//test runs with each of builds, expanded testcases have their own build
+func (t *AstTestCase) buildTestCases() []*AstTestCase {
+	if t.GetBuild() != nil {
+		return []*AstTestCase{t}
+	}
+	testcases := make([]*AstTestCase, 0)
+	for _, build := range t.GetBuilds() {
+		inst := t.Clone()
+		inst.build = build
+		inst.builds = nil
+		testcases = append(testcases, inst)
 	}
 	return testcases
 }
@@ -949,9 +1266,14 @@ func (t *AstTestCase) Link() *errors.JVSAstError {
 		return err
 	}
 	//set build and check test
-	if !t.GetBuild().GetTestDiscoverer().IsValidTest(t.Name) {
-		return errors.JVSAstLinkError(t.Name+"("+t.file+")", t.Name+" is not valid test of build"+t.GetBuild().Name+"\n"+
-			"valid tests:\n"+strings.Join(t.GetBuild().GetTestDiscoverer().TestList(), "\n"))
+	if len(t.GetBuilds()) == 0 {
+		return errors.JVSAstLinkError(t.Name+"("+t.file+")", "build of "+t.Name+" is undef!")
+	}
+	for _, build := range t.GetBuilds() {
+		if !build.GetTestDiscoverer().IsValidTest(t.Name) {
+			return errors.JVSAstLinkError(t.Name+"("+t.file+")", t.Name+" is not valid test of build"+build.Name+"\n"+
+				"valid tests:\n"+strings.Join(build.GetTestDiscoverer().TestList(), "\n"))
+		}
 	}
 
 	return nil
@@ -1032,9 +1354,24 @@ func (t *AstGroup) ParseArgs() {
 	})
 }
 
+//tests and groups with matrix are expanded to variants
 func (t *AstGroup) GetTestCases() []*AstTestCase {
 	testcases := make([]*AstTestCase, 0)
-	testcases = append(testcases, t.Tests...)
+	if t.variants != nil {
+		for _, variant := range t.variants {
+			testcases = append(testcases, t.matrixGroup(variant).GetTestCases()...)
+		}
+		return testcases
+	}
+	for _, test := range t.Tests {
+		if test.variants == nil {
+			testcases = append(testcases, test.buildTestCases()...)
+			continue
+		}
+		for _, variant := range test.variants {
+			testcases = append(testcases, test.matrixTestCase(variant).buildTestCases()...)
+		}
+	}
 	for _, group := range t.Groups {
 		testcases = append(testcases, group.GetTestCases()...)
 	}
@@ -1144,7 +1481,11 @@ func (t *AstGroup) GetHierString(space int) string {
 	return astHierFmt(t.GetName()+":", space, func() string {
 		return t.astTest.GetHierString(nextSpace) +
 			astHierFmt("Builds:", nextSpace, func() string {
-				return fmt.Sprintln(strings.Repeat(" ", nextSpace) + t.GetBuild().Name)
+				s := ""
+				for _, b := range t.GetBuilds() {
+					s += fmt.Sprintln(strings.Repeat(" ", nextSpace) + b.Name)
+				}
+				return s
 			}) +
 			astHierFmt("Tests:", nextSpace, func() string {
 				s := ""
@@ -1176,6 +1517,7 @@ type astRoot struct {
 	Options map[string]*astOption
 	Builds  map[string]*AstBuild
 	Groups  map[string]*AstGroup
+	Waivers []*Waiver
 }
 
 func newAstRoot() *astRoot {
@@ -1216,6 +1558,23 @@ func (t *astRoot) GetAllGroups() []string {
 	return groups
 }
 
+//tags of test in all groups which run it with the build
+func (t *astRoot) GetTestTags(buildName, testName string) []string {
+	tags := utils.NewStringMapSet()
+	for _, group := range t.Groups {
+		for _, test := range group.GetTestCases() {
+			if test.Name == testName && test.GetBuild() != nil && test.GetBuild().Name == buildName {
+				for _, tag := range test.GetTags() {
+					tags.AddKey(tag)
+				}
+			}
+		}
+	}
+	keys := tags.Keys()
+	sort.Strings(keys)
+	return keys
+}
+
 func (t *astRoot) KeywordsChecker(s string) (bool, *utils.StringMapSet, string) {
 	return true, nil, ""
 }
@@ -1298,6 +1657,33 @@ func (t *astRoot) Parse(cfg map[interface{}]interface{}) *errors.JVSAstError {
 		}
 		return err
 	}
+
+	//parsing waivers, waivers in all files are collected
+	if err := CfgToAstItemOptional(cfg, "waivers", WithCheckList(func(item []interface{}) *errors.JVSAstError {
+		for _, waiverCfg := range item {
+			waiver, err := astParseWaiver(waiverCfg)
+			if err != nil {
+				return err
+			}
+			t.Waivers = append(t.Waivers, waiver)
+		}
+		return nil
+	})); err != nil {
+		if err.Item == "" {
+			err.Item = "waivers"
+		}
+		return err
+	}
+	return nil
+}
+
+//the first waiver which waives the failed test, key is "build__group...__test__seed" of test
+func (t *astRoot) GetWaiver(key string, result *errors.JVSRuntimeResult) *Waiver {
+	for _, waiver := range t.Waivers {
+		if waiver.Waive(key, result) {
+			return waiver
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,67 @@
+package build_sign_test
+
+import (
+	"github.com/shady831213/jarvism/core/loader"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+const cfg = `
+env:
+  simulator:
+    type:
+      "vcs"
+
+builds:
+  build1:
+    compile_option:
+      - -sverilog
+`
+
+func writeFile(t *testing.T, file, content string) {
+	if err := os.MkdirAll(path.Dir(file), os.ModePerm); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+}
+
+//fixtures are created in a temp project, so that they can be changed
+func TestBuildSign(t *testing.T) {
+	prj, err := ioutil.TempDir("", "jvs_build_sign")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(prj)
+	writeFile(t, path.Join(prj, "jarvism_cfg", "build.yaml"), cfg)
+	writeFile(t, path.Join(prj, "testcases", "test1", "test1.sv"), "class test1;endclass\n")
+	writeFile(t, path.Join(prj, "testcases", "common", "pkg.sv"), "package pkg;endpackage\n")
+	os.Setenv("JVS_PRJ_HOME", prj)
+	if err := loader.Load(path.Join(prj, "jarvism_cfg")); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	build := loader.GetJvsAstRoot().GetBuild("build1")
+	sign := build.GetSign()
+	if build.GetSign() != sign {
+		t.Error("expect the same sign of unchanged build!")
+		t.FailNow()
+	}
+	//not in filelist, sub dirs of incdirs are not searched
+	writeFile(t, path.Join(prj, "testcases", "common", "pkg.sv"), "package pkg;\nendpackage\n")
+	if build.GetSign() != sign {
+		t.Error("expect the same sign after file out of filelist changed!")
+		t.FailNow()
+	}
+	writeFile(t, path.Join(prj, "testcases", "test1", "test1.sv"), "class test1;\nendclass\n")
+	if build.GetSign() == sign {
+		t.Error("expect a new sign after test1.sv changed!")
+		t.FailNow()
+	}
+}
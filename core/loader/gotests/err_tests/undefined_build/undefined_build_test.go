@@ -0,0 +1,20 @@
+package undefined_build
+
+import (
+	"github.com/shady831213/jarvism/core/loader"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUndefinedBuild(t *testing.T) {
+	err := loader.Load("testFiles/jarvism_cfg")
+	if err == nil || !strings.Contains(err.Error(), "build of test1 is undef") {
+		t.Error("expect undefined build err but get", err)
+		t.FailNow()
+	}
+}
+
+func init() {
+	os.Setenv("JVS_PRJ_HOME", "testFiles")
+}
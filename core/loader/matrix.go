@@ -0,0 +1,130 @@
+package loader
+
+import (
+	"fmt"
+	"github.com/shady831213/jarvism/core/errors"
+	"github.com/shady831213/jarvism/core/utils"
+	"sort"
+	"strings"
+)
+
+//one dimension of matrix, an option and its values
+type matrixAxis struct {
+	option string
+	values []string
+}
+
+//one combination of matrix values
+//
+//name is like "cfg_mode=a,wave=VPD", it is used as a group level in test name
+type matrixVariant struct {
+	name       string
+	optionArgs *utils.StringMapSet
+}
+
+//matrix:
+//  wave: [VPD, FSDB]
+//  cfg_mode: [a, b, c]
+//
+//axes are sorted by option name, values keep the order in config
+func astParseMatrix(item interface{}) ([]*matrixAxis, *errors.JVSAstError) {
+	cfg, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.JVSAstParseError("matrix", fmt.Sprintf("expect a map but get %T!", item))
+	}
+	axes := make([]*matrixAxis, 0)
+	for k, v := range cfg {
+		option, ok := k.(string)
+		if !ok {
+			return nil, errors.JVSAstParseError("matrix", fmt.Sprintf("expect option name but get %v!", k))
+		}
+		values, ok := v.([]interface{})
+		if !ok || len(values) == 0 {
+			return nil, errors.JVSAstParseError("matrix", fmt.Sprintf("expect a list of values of %s but get %T!", option, v))
+		}
+		axis := &matrixAxis{option, make([]string, 0)}
+		for _, value := range values {
+			s := fmt.Sprint(value)
+			if strings.Contains(s, "__") || strings.ContainsAny(s, " /") {
+				return nil, errors.JVSAstParseError("matrix", "value \""+s+"\" of "+option+" can't contain \"__\", \" \" or \"/\"!")
+			}
+			axis.values = append(axis.values, s)
+		}
+		axes = append(axes, axis)
+	}
+	sort.Slice(axes, func(i, j int) bool {
+		return axes[i].option < axes[j].option
+	})
+	return axes, nil
+}
+
+//"true" applies a bool option, "false" doesn't apply it
+func matrixArg(option, value string) string {
+	switch value {
+	case "true":
+		return "-" + option
+	case "false":
+		return ""
+	}
+	return "-" + option + " " + value
+}
+
+//cartesian product of axes, options must have been all parsed
+func linkMatrix(axes []*matrixAxis) ([]*matrixVariant, error) {
+	variants := []*matrixVariant{{"", utils.NewStringMapSet()}}
+	for _, axis := range axes {
+		product := make([]*matrixVariant, 0)
+		for _, v := range variants {
+			for _, value := range axis.values {
+				variant := &matrixVariant{axis.option + "=" + value, utils.StringMapSetUnion(v.optionArgs, utils.NewStringMapSet())}
+				if v.name != "" {
+					variant.name = v.name + "," + variant.name
+				}
+				if arg := matrixArg(axis.option, value); arg != "" {
+					opt, err := GetJvsAstOption(arg)
+					if err != nil {
+						return nil, err
+					}
+					variant.optionArgs.Add(opt.GetName(), opt.Clone())
+				}
+				product = append(product, variant)
+			}
+		}
+		variants = product
+	}
+	return variants, nil
+}
+
+//a group between parent and the variant of test or group, carrying the variant name
+func newMatrixGroup(variant *matrixVariant, parent astTestOpts) *AstGroup {
+	inst := NewAstGroup(variant.name)
+	inst.parent = parent
+	inst.linked = true
+	return inst
+}
+
+//variant of test, options of matrix override args of the test
+func (t *AstTestCase) matrixTestCase(variant *matrixVariant) *AstTestCase {
+	inst := t.Clone()
+	inst.parent = newMatrixGroup(variant, t.parent)
+	inst.optionArgs = utils.StringMapSetUnion(t.optionArgs, variant.optionArgs)
+	inst.matrix, inst.variants = nil, nil
+	return inst
+}
+
+//variant of group, tests and subgroups inherit options of matrix as args of group
+func (t *AstGroup) matrixGroup(variant *matrixVariant) *AstGroup {
+	inst := newMatrixGroup(variant, t)
+	inst.optionArgs = variant.optionArgs
+	variantGroup := t.Clone()
+	variantGroup.matrix, variantGroup.variants = nil, nil
+	inst.Tests = variantGroup.Tests
+	for _, test := range inst.Tests {
+		test.parent = inst
+	}
+	inst.Groups = variantGroup.Groups
+	for _, group := range inst.Groups {
+		group.parent = inst
+	}
+	return inst
+}
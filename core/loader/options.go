@@ -7,13 +7,17 @@ import (
 	"github.com/shady831213/jarvism/core"
 	jvsErrors "github.com/shady831213/jarvism/core/errors"
 	"github.com/shady831213/jarvism/core/options"
+	"hash/fnv"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"os"
 	"path"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 //user defined options
@@ -64,6 +68,14 @@ func GetJvsAstOption(arg string) (JvsAstOption, error) {
 	return v, nil
 }
 
+//convert option to cmdline arg, e.g. "-vh", "-repeat 10"
+func JvsAstOptionToArg(v JvsAstOption) string {
+	if b, ok := v.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() && v.String() == "true" {
+		return "-" + v.GetName()
+	}
+	return "-" + v.GetName() + " " + v.String()
+}
+
 func LoadBuildInOptions(configFile string) error {
 	if configFile == "" {
 		return nil
@@ -132,7 +144,7 @@ func (t *RepeatOption) Set(s string) error {
 }
 
 func (t *RepeatOption) String() string {
-	return string(t.n)
+	return strconv.Itoa(t.n)
 }
 
 func (t *RepeatOption) TestHandler(test *AstTestCase) {
@@ -140,8 +152,8 @@ func (t *RepeatOption) TestHandler(test *AstTestCase) {
 	if test.seeds == nil {
 		test.seeds = make([]int, 0)
 		seedsMap := make(map[int]interface{})
-		for len(seedsMap) < t.n {
-			seed := jvsRand.Intn(math.MaxInt32)
+		for i := 0; len(seedsMap) < t.n; i++ {
+			seed := deriveSeed(test.GetName(), i)
 			if _, ok := seedsMap[seed]; !ok {
 				seedsMap[seed] = nil
 				test.seeds = append(test.seeds, seed)
@@ -194,19 +206,107 @@ func (t *SeedOption) Set(s string) error {
 }
 
 func (t *SeedOption) String() string {
-	return string(t.n)
+	return strconv.Itoa(t.n)
 }
 
 func (t *SeedOption) TestHandler(test *AstTestCase) {
+	//-seeds_file has higher priority
+	if _, ok := test.GetOptionArgs().Get("seeds_file"); ok {
+		return
+	}
 	test.seeds = make([]int, 1)
 	if t.n == 0 {
-		test.seeds[0] = jvsRand.Intn(math.MaxInt32)
+		test.seeds[0] = deriveSeed(test.GetName(), 0)
 		return
 	}
 	test.seeds[0] = t.n
 }
 
+//------------------------
+
+type SeedsFileOption struct {
+	jvsAstNonBoolOption
+	file  string
+	seeds []int
+}
+
+func newSeedsFileOption() *SeedsFileOption {
+	inst := new(SeedsFileOption)
+	return inst
+}
+
+func (t *SeedsFileOption) GetName() string {
+	return "seeds_file"
+}
+
+func (t *SeedsFileOption) Clone() JvsAstOption {
+	inst := newSeedsFileOption()
+	inst.file = t.file
+	inst.seeds = t.seeds
+	return inst
+}
+
+//seeds are separated by spaces, commas or new lines, "#" starts a comment
+func (t *SeedsFileOption) Set(s string) error {
+	file := os.ExpandEnv(s)
+	bytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	seeds := make([]int, 0)
+	for _, line := range strings.Split(string(bytes), "\n") {
+		line = strings.SplitN(line, "#", 2)[0]
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			seed, err := strconv.Atoi(field)
+			if err != nil {
+				return errors.New("invalid seed " + field + " in " + file + "!")
+			}
+			seeds = append(seeds, seed)
+		}
+	}
+	if len(seeds) == 0 {
+		return errors.New("no seed in " + file + "!")
+	}
+	t.file = s
+	t.seeds = seeds
+	return nil
+}
+
+func (t *SeedsFileOption) String() string {
+	return t.file
+}
+
+func (t *SeedsFileOption) TestHandler(test *AstTestCase) {
+	test.SetSeeds(t.seeds)
+}
+
+func (t *SeedsFileOption) Usage() string {
+	return "run testcase with each seed in file, seeds are separated by spaces, commas or new lines"
+}
+
 var jvsRand *rand.Rand
+var jvsMasterSeed int
+
+//set master seed of job, a random one is picked if seed is 0, return the master seed
+func SetMasterSeed(seed int) int {
+	if seed == 0 {
+		seed = jvsRand.Intn(math.MaxInt32-1) + 1
+	}
+	jvsMasterSeed = seed
+	return seed
+}
+
+func GetMasterSeed() int {
+	return jvsMasterSeed
+}
+
+//seeds only depend on master seed, test name and index,
+//so that the same seeds are regenerated with the same master seed whatever order tests are flattened in
+func deriveSeed(name string, i int) int {
+	h := fnv.New64a()
+	h.Write([]byte(strconv.Itoa(jvsMasterSeed) + "__" + name + "__" + strconv.Itoa(i)))
+	return int(h.Sum64() % math.MaxInt32)
+}
 
 func init() {
 	jvsRand = rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -215,4 +315,5 @@ func init() {
 	}
 	RegisterJvsAstOption(newRepeatOption())
 	RegisterJvsAstOption(newSeedOption())
+	RegisterJvsAstOption(newSeedsFileOption())
 }
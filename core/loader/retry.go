@@ -0,0 +1,120 @@
+package loader
+
+import (
+	"fmt"
+	"github.com/shady831213/jarvism/core/errors"
+	"regexp"
+	"strings"
+)
+
+//retry policy of failing tests
+//
+//Times: max retry times
+//
+//NewSeed: retry with a new seed, default is the same seed
+//
+//Statuses: only retry tests of these statuses, default is fail, unknown and timeout
+//
+//Match: only retry tests with messages matching it, nil matches all
+type RetryPolicy struct {
+	Times    int
+	NewSeed  bool
+	Statuses []errors.JVSRuntimeStatus
+	Match    *regexp.Regexp
+}
+
+func NewRetryPolicy(times int) *RetryPolicy {
+	inst := new(RetryPolicy)
+	inst.Times = times
+	inst.Statuses = []errors.JVSRuntimeStatus{errors.JVSRuntimeFail, errors.JVSRuntimeUnknown, errors.JVSRuntimeTimeout}
+	return inst
+}
+
+//retried is the number of retries have been done
+func (p *RetryPolicy) ShouldRetry(result *errors.JVSRuntimeResult, retried int) bool {
+	if p == nil || retried >= p.Times {
+		return false
+	}
+	matched := false
+	for _, status := range p.Statuses {
+		if result.Status == status {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+	return p.Match == nil || p.Match.MatchString(result.GetMsg())
+}
+
+//retry:
+//  times: 2
+//  new_seed: true
+//  status: [unknown, timeout]
+//  match: .*license.*
+func astParseRetry(item interface{}) (*RetryPolicy, *errors.JVSAstError) {
+	//retry: 2
+	if times, ok := item.(int); ok {
+		return NewRetryPolicy(times), nil
+	}
+	cfg, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.JVSAstParseError("retry", fmt.Sprintf("expect a map or int times but get %T!", item))
+	}
+	for k := range cfg {
+		if key, _ := k.(string); key != "times" && key != "new_seed" && key != "status" && key != "match" {
+			return nil, errors.JVSAstParseError("retry", fmt.Sprintf("unknown keyword %v, valid keywords are [times, new_seed, status, match]!", k))
+		}
+	}
+	p := NewRetryPolicy(0)
+	if err := CfgToAstItemRequired(cfg, "times", func(item interface{}) *errors.JVSAstError {
+		v, ok := item.(int)
+		if !ok {
+			return errors.JVSAstParseError("times", fmt.Sprintf("expect an int but get %T!", item))
+		}
+		p.Times = v
+		return nil
+	}); err != nil {
+		return nil, errors.JVSAstParseError("retry", err.Msg)
+	}
+	if err := CfgToAstItemOptional(cfg, "new_seed", func(item interface{}) *errors.JVSAstError {
+		v, ok := item.(bool)
+		if !ok {
+			return errors.JVSAstParseError("new_seed", fmt.Sprintf("expect a bool but get %T!", item))
+		}
+		p.NewSeed = v
+		return nil
+	}); err != nil {
+		return nil, errors.JVSAstParseError("retry", err.Msg)
+	}
+	if err := CfgToAstItemOptional(cfg, "status", WithCheckList(func(item []interface{}) *errors.JVSAstError {
+		p.Statuses = make([]errors.JVSRuntimeStatus, 0)
+		for _, s := range item {
+			name := strings.ToUpper(fmt.Sprint(s))
+			status := errors.StatusFromString(name)
+			if errors.StatusString(status) != name {
+				return errors.JVSAstParseError("status", "unknown status "+name+"!")
+			}
+			p.Statuses = append(p.Statuses, status)
+		}
+		return nil
+	})); err != nil {
+		return nil, errors.JVSAstParseError("retry", err.Msg)
+	}
+	if err := CfgToAstItemOptional(cfg, "match", func(item interface{}) *errors.JVSAstError {
+		v, ok := item.(string)
+		if !ok {
+			return errors.JVSAstParseError("match", fmt.Sprintf("expect a string but get %T!", item))
+		}
+		match, err := regexp.Compile(v)
+		if err != nil {
+			return errors.JVSAstParseError("match", err.Error())
+		}
+		p.Match = match
+		return nil
+	}); err != nil {
+		return nil, errors.JVSAstParseError("retry", err.Msg)
+	}
+	return p, nil
+}
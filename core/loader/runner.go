@@ -26,11 +26,13 @@ type Runner interface {
 	RunTest(*AstTestCase, CmdRunner) *errors.JVSRuntimeResult
 }
 
+//Deprecated: names are for display only, use AstBuild.Identity
 func ParseBuildName(name string) (jobId, buildName string) {
 	s := strings.Split(name, "__")
 	return s[0], s[1]
 }
 
+//Deprecated: names are for display only and break if a test or group name contains "__", use AstTestCase.Identity
 func ParseTestName(name string) (jobId, buildName, testName, seed string, groupsName []string) {
 	s := strings.Split(name, "__")
 	jobId = s[0]
@@ -0,0 +1,56 @@
+package loader
+
+import (
+	"math/rand"
+	"sort"
+)
+
+func sampleKey(t *AstTestCase) string {
+	return t.GetBuild().Name + "__" + t.GetName()
+}
+
+//pick n runs of tests randomly with replacement, a test with weight w is picked w times as often as a test with weight 1.
+//
+//Picked times of each test are returned, tests never picked are not in it.
+//Picking only depends on master seed and tests, so the same master seed picks the same runs.
+func SampleTestCases(tests []*AstTestCase, n int) map[*AstTestCase]int {
+	picked := make(map[*AstTestCase]int)
+	sorted := make([]*AstTestCase, len(tests))
+	copy(sorted, tests)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sampleKey(sorted[i]) < sampleKey(sorted[j])
+	})
+	total := 0
+	for _, t := range sorted {
+		total += t.GetWeight()
+	}
+	if total == 0 {
+		return picked
+	}
+	r := rand.New(rand.NewSource(int64(jvsMasterSeed)))
+	for i := 0; i < n; i++ {
+		w := r.Intn(total)
+		for _, t := range sorted {
+			if w < t.GetWeight() {
+				picked[t]++
+				break
+			}
+			w -= t.GetWeight()
+		}
+	}
+	return picked
+}
+
+//a test picked n times runs with n seeds, seeds are derived as -repeat n does
+func (t *AstTestCase) SetSampledSeeds(n int) {
+	seeds := make([]int, n)
+	for i := range seeds {
+		seeds[i] = deriveSeed(t.GetName(), i)
+	}
+	t.SetSeeds(seeds)
+}
+
+//testcase with the nth seed, seeds are derived as -repeat does
+func (t *AstTestCase) NthTestCase(n int) *AstTestCase {
+	return t.newFlattenTestCase(deriveSeed(t.GetName(), n))
+}
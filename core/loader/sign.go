@@ -0,0 +1,194 @@
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//$VAR or ${VAR}
+var envRefRe = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)\}?`)
+
+//content of build which decides its hash and build dir, a changed source or setup yields a new build
+//
+//It includes build name, resolved compile command, environment variables referenced in compile actions and options,
+//simulator identity, the generated filelist and files referenced by -f, -F, -file, -v, -y and +incdir+ of compile command,
+//with size and mtime of each file.
+func (t *AstBuild) GetSign() string {
+	lines := []string{"build:" + t.Name}
+	lines = append(lines, t.compileSign()...)
+	lines = append(lines, t.envSign()...)
+	lines = append(lines, simulatorSign()...)
+	lines = append(lines, t.fileListSign()...)
+	lines = append(lines, t.optionFileSign()...)
+	return strings.Join(lines, "\n")
+}
+
+//runs of spaces are collapsed, cloned builds have extra spaces between items
+func (t *AstBuild) compileSign() []string {
+	return []string{"pre_compile:" + strings.Join(strings.Fields(t.PreCompileAction()), " "),
+		"compile:" + strings.Join(strings.Fields(GetCurSimulator().CompileCmd()+" "+t.CompileOption()), " "),
+		"post_compile:" + strings.Join(strings.Fields(t.PostCompileAction()), " ")}
+}
+
+//referenced environment variables in order of name, unset ones are empty
+func (t *AstBuild) envSign() []string {
+	names := make(map[string]bool)
+	for _, s := range []string{t.PreCompileAction(), t.CompileOption(), t.PostCompileAction()} {
+		for _, m := range envRefRe.FindAllStringSubmatch(s, -1) {
+			names[m[1]] = true
+		}
+	}
+	lines := make([]string, 0, len(names))
+	for name := range names {
+		lines = append(lines, "env:"+name+"="+os.Getenv(name))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+//name and commands of simulator, and the compiler found in $PATH
+func simulatorSign() []string {
+	sim := GetCurSimulator()
+	lines := []string{"simulator:" + sim.Name() + " " + sim.CompileCmd() + " " + sim.SimCmd()}
+	fields := strings.Fields(sim.CompileCmd())
+	if len(fields) == 0 {
+		return lines
+	}
+	if p, err := exec.LookPath(fields[0]); err == nil {
+		lines = append(lines, fileSign("compiler", p)...)
+	}
+	return lines
+}
+
+//filelist generated as runner does, dirs in filelist are incdirs
+func (t *AstBuild) fileListSign() []string {
+	discoverer := t.GetTestDiscoverer()
+	items := discoverer.TestFileList()
+	if len(items) == 0 {
+		return nil
+	}
+	items = append(append([]string{}, items...), discoverer.TestDir())
+	sort.Strings(items)
+	fileList, err := GetCurSimulator().GetFileList(items...)
+	if err != nil {
+		return []string{"filelist:" + err.Error()}
+	}
+	files := make(map[string]bool)
+	for _, item := range items {
+		for _, line := range fileSign("file", os.ExpandEnv(item)) {
+			files[line] = true
+		}
+	}
+	lines := make([]string, 0, len(files))
+	for line := range files {
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return append([]string{"filelist:" + fileList}, lines...)
+}
+
+//files referenced by compile command, relative paths are relative to current dir
+func (t *AstBuild) optionFileSign() []string {
+	args := strings.Fields(os.ExpandEnv(GetCurSimulator().CompileCmd() + " " + t.CompileOption()))
+	files := make(map[string]bool)
+	argFiles(args, "", false, files)
+	lines := make([]string, 0)
+	for f := range files {
+		lines = append(lines, fileSign("option_file", f)...)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+//collect files of -f, -F, -file, -v, -y and +incdir+ in args, filelists are parsed recursively,
+//other items of filelists are source files
+func argFiles(args []string, dir string, inFileList bool, files map[string]bool) {
+	abs := func(p string) string {
+		if dir == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	for i := 0; i < len(args); i++ {
+		arg := strings.Trim(args[i], `"'`)
+		switch {
+		case arg == "-f" || arg == "-F" || arg == "-file":
+			if i+1 >= len(args) {
+				return
+			}
+			i++
+			f := abs(strings.Trim(args[i], `"'`))
+			if files[f] {
+				continue
+			}
+			files[f] = true
+			//-F items are relative to dir of filelist
+			subDir := dir
+			if arg == "-F" {
+				subDir = filepath.Dir(f)
+			}
+			argFiles(readFileList(f), subDir, true, files)
+		case arg == "-v" || arg == "-y":
+			if i+1 >= len(args) {
+				return
+			}
+			i++
+			files[abs(strings.Trim(args[i], `"'`))] = true
+		case strings.HasPrefix(arg, "+incdir+"):
+			for _, d := range strings.Split(strings.TrimPrefix(arg, "+incdir+"), "+") {
+				if d != "" {
+					files[abs(d)] = true
+				}
+			}
+		case inFileList && arg != "" && !strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "+"):
+			files[abs(arg)] = true
+		}
+	}
+}
+
+//items of filelist, comments are removed and env vars are expanded
+func readFileList(p string) []string {
+	content, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil
+	}
+	items := make([]string, 0)
+	for _, line := range strings.Split(string(content), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		items = append(items, strings.Fields(os.ExpandEnv(line))...)
+	}
+	return items
+}
+
+//size and mtime of file, or of each file directly in dir, sub dirs are not walked as incdirs and libraries are not searched recursively
+func fileSign(kind, p string) []string {
+	info, err := os.Stat(p)
+	if err != nil {
+		return []string{kind + ":" + p + " " + err.Error()}
+	}
+	if !info.IsDir() {
+		return []string{kind + ":" + p + " " + strconv.FormatInt(info.Size(), 10) + " " + strconv.FormatInt(info.ModTime().UnixNano(), 10)}
+	}
+	infos, err := ioutil.ReadDir(p)
+	if err != nil {
+		return []string{kind + ":" + p + " " + err.Error()}
+	}
+	lines := make([]string, 0)
+	for _, info := range infos {
+		if !info.IsDir() {
+			lines = append(lines, kind+":"+filepath.Join(p, info.Name())+" "+strconv.FormatInt(info.Size(), 10)+" "+strconv.FormatInt(info.ModTime().UnixNano(), 10))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,172 @@
+package loader
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+//tag expression, e.g. "smoke && !long", "(ddr || pcie) && !long"
+//
+//"!" has the highest priority, then "&&", then "||"
+type TagExpr interface {
+	Match(tags []string) bool
+}
+
+type tagExprTag string
+
+func (e tagExprTag) Match(tags []string) bool {
+	for _, tag := range tags {
+		if tag == string(e) {
+			return true
+		}
+	}
+	return false
+}
+
+type tagExprNot struct {
+	e TagExpr
+}
+
+func (e *tagExprNot) Match(tags []string) bool {
+	return !e.e.Match(tags)
+}
+
+type tagExprAnd struct {
+	l, r TagExpr
+}
+
+func (e *tagExprAnd) Match(tags []string) bool {
+	return e.l.Match(tags) && e.r.Match(tags)
+}
+
+type tagExprOr struct {
+	l, r TagExpr
+}
+
+func (e *tagExprOr) Match(tags []string) bool {
+	return e.l.Match(tags) || e.r.Match(tags)
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+func tagExprTokens(s string) ([]string, error) {
+	tokens := make([]string, 0)
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case runes[i] == '!' || runes[i] == '(' || runes[i] == ')':
+			tokens = append(tokens, string(runes[i]))
+			i++
+		case (runes[i] == '&' || runes[i] == '|') && i+1 < len(runes) && runes[i+1] == runes[i]:
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		case isTagRune(runes[i]):
+			j := i
+			for j < len(runes) && isTagRune(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			return nil, errors.New("unexpected " + string(runes[i]) + " in tag expression " + s + "!")
+		}
+	}
+	return tokens, nil
+}
+
+type tagExprParser struct {
+	expr   string
+	tokens []string
+}
+
+func (p *tagExprParser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+func (p *tagExprParser) next() string {
+	token := p.peek()
+	if len(p.tokens) > 0 {
+		p.tokens = p.tokens[1:]
+	}
+	return token
+}
+
+func (p *tagExprParser) parseOr() (TagExpr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &tagExprOr{l, r}
+	}
+	return l, nil
+}
+
+func (p *tagExprParser) parseAnd() (TagExpr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &tagExprAnd{l, r}
+	}
+	return l, nil
+}
+
+func (p *tagExprParser) parseUnary() (TagExpr, error) {
+	switch token := p.next(); token {
+	case "!":
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &tagExprNot{e}, nil
+	case "(":
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New("missing ) in tag expression " + p.expr + "!")
+		}
+		return e, nil
+	case "", ")", "&&", "||":
+		return nil, errors.New("expect a tag but get \"" + token + "\" in tag expression " + p.expr + "!")
+	default:
+		return tagExprTag(token), nil
+	}
+}
+
+func ParseTagExpr(s string) (TagExpr, error) {
+	tokens, err := tagExprTokens(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &tagExprParser{strings.TrimSpace(s), tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token != "" {
+		return nil, errors.New("unexpected " + token + " in tag expression " + s + "!")
+	}
+	return e, nil
+}
@@ -0,0 +1,144 @@
+package loader
+
+import (
+	"fmt"
+	"github.com/shady831213/jarvism/core/errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//known failure, failed tests matching it are waived
+//
+//Test: matches "build__group...__test__seed" of test, nil matches all
+//
+//Match: matches messages of failed test, nil matches all
+//
+//Expires: waiver doesn't work after this day, zero never expires
+//
+//Owner, Reason: who owns the failure and why it is waived
+type Waiver struct {
+	Test    *regexp.Regexp
+	Match   *regexp.Regexp
+	Expires time.Time
+	Owner   string
+	Reason  string
+}
+
+func (w *Waiver) Expired(now time.Time) bool {
+	return !w.Expires.IsZero() && !now.Before(w.Expires.AddDate(0, 0, 1))
+}
+
+//key is "build__group...__test__seed" of test, only fail, unknown and timeout are waived
+func (w *Waiver) Waive(key string, result *errors.JVSRuntimeResult) bool {
+	if !errors.IsFailed(result.Status) {
+		return false
+	}
+	if w.Expired(time.Now()) {
+		return false
+	}
+	if w.Test != nil && !w.Test.MatchString(key) {
+		return false
+	}
+	return w.Match == nil || w.Match.MatchString(result.GetMsg())
+}
+
+func (w *Waiver) String() string {
+	fields := make([]string, 0)
+	if w.Test != nil {
+		fields = append(fields, "test:"+w.Test.String())
+	}
+	if w.Match != nil {
+		fields = append(fields, "match:"+w.Match.String())
+	}
+	if w.Owner != "" {
+		fields = append(fields, "owner:"+w.Owner)
+	}
+	if !w.Expires.IsZero() {
+		fields = append(fields, "expires:"+w.Expires.Format("2006-01-02"))
+	}
+	if w.Reason != "" {
+		fields = append(fields, "reason:"+w.Reason)
+	}
+	return "waiver(" + strings.Join(fields, ", ") + ")"
+}
+
+func astParseWaiverRegexp(cfg map[interface{}]interface{}, key string) (*regexp.Regexp, *errors.JVSAstError) {
+	var pat *regexp.Regexp
+	if err := CfgToAstItemOptional(cfg, key, func(item interface{}) *errors.JVSAstError {
+		v, ok := item.(string)
+		if !ok {
+			return errors.JVSAstParseError(key, fmt.Sprintf("expect a string but get %T!", item))
+		}
+		p, err := regexp.Compile(v)
+		if err != nil {
+			return errors.JVSAstParseError(key, err.Error())
+		}
+		pat = p
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return pat, nil
+}
+
+//waivers:
+//  - test: __test1__
+//    match: .*license.*
+//    expires: 2020-12-31
+//    owner: alice
+//    reason: license server is unstable
+//
+//at least one of test and match must be assigned
+func astParseWaiver(item interface{}) (*Waiver, *errors.JVSAstError) {
+	cfg, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.JVSAstParseError("waivers", fmt.Sprintf("expect a map but get %T!", item))
+	}
+	for k := range cfg {
+		if key, _ := k.(string); key != "test" && key != "match" && key != "expires" && key != "owner" && key != "reason" {
+			return nil, errors.JVSAstParseError("waivers", fmt.Sprintf("unknown keyword %v, valid keywords are [test, match, expires, owner, reason]!", k))
+		}
+	}
+	w := new(Waiver)
+	var err *errors.JVSAstError
+	if w.Test, err = astParseWaiverRegexp(cfg, "test"); err != nil {
+		return nil, errors.JVSAstParseError("waivers", err.Msg)
+	}
+	if w.Match, err = astParseWaiverRegexp(cfg, "match"); err != nil {
+		return nil, errors.JVSAstParseError("waivers", err.Msg)
+	}
+	if w.Test == nil && w.Match == nil {
+		return nil, errors.JVSAstParseError("waivers", fmt.Sprintf("test or match must be assigned but get %v!", cfg))
+	}
+	if err := CfgToAstItemOptional(cfg, "expires", func(item interface{}) *errors.JVSAstError {
+		switch v := item.(type) {
+		case time.Time:
+			w.Expires = time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.Local)
+		case string:
+			expires, err := time.ParseInLocation("2006-01-02", v, time.Local)
+			if err != nil {
+				return errors.JVSAstParseError("expires", "expect a date like 2006-01-02 but get "+v+"!")
+			}
+			w.Expires = expires
+		default:
+			return errors.JVSAstParseError("expires", fmt.Sprintf("expect a date like 2006-01-02 but get %T!", item))
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.JVSAstParseError("waivers", err.Msg)
+	}
+	if err := CfgToAstItemOptional(cfg, "owner", func(item interface{}) *errors.JVSAstError {
+		w.Owner = fmt.Sprint(item)
+		return nil
+	}); err != nil {
+		return nil, errors.JVSAstParseError("waivers", err.Msg)
+	}
+	if err := CfgToAstItemOptional(cfg, "reason", func(item interface{}) *errors.JVSAstError {
+		w.Reason = fmt.Sprint(item)
+		return nil
+	}); err != nil {
+		return nil, errors.JVSAstParseError("waivers", err.Msg)
+	}
+	return w, nil
+}
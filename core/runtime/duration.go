@@ -0,0 +1,130 @@
+package runtime
+
+import (
+	"github.com/shady831213/jarvism/core/loader"
+	"github.com/shady831213/jarvism/core/utils"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type launchTest struct {
+	test *loader.AstTestCase
+	flow *runFlow
+	//seeds have been launched
+	launched int
+}
+
+/*
+launcher keeps launching new seeds of tests until -duration expires, then running tests are drained.
+
+Tests are picked in proportion to their weight, and only tests whose build is done are picked.
+Running tests launched are no more than -max_test_job, or the number of tests if it is unlimited.
+*/
+type launcher struct {
+	sync.Mutex
+	tests    []*launchTest
+	ready    map[*runFlow]bool
+	rand     *rand.Rand
+	deadline time.Time
+	expired  bool
+	limit    int
+	running  int
+	total    int
+}
+
+func newLauncher(masterSeed int, duration time.Duration) *launcher {
+	inst := new(launcher)
+	inst.tests = make([]*launchTest, 0)
+	inst.ready = make(map[*runFlow]bool)
+	inst.rand = rand.New(rand.NewSource(int64(masterSeed)))
+	inst.deadline = time.Now().Add(duration)
+	return inst
+}
+
+func (l *launcher) add(test *loader.AstTestCase, flow *runFlow) {
+	l.tests = append(l.tests, &launchTest{test, flow, 0})
+	flow.launcher = l
+	l.limit = schedLimit(runTimeMaxTestJob)
+	if l.limit <= 0 {
+		l.limit = len(l.tests)
+	}
+}
+
+//build of flow is done, its tests can be launched
+func (l *launcher) start(f *runFlow) {
+	l.Lock()
+	defer l.Unlock()
+	l.ready[f] = true
+	l.fill()
+}
+
+//called by test job before it is done, so that scheduler waits for tests launched by it
+func (l *launcher) done() {
+	l.Lock()
+	defer l.Unlock()
+	l.running--
+	l.fill()
+}
+
+//number of tests have been launched
+func (l *launcher) launchedCnt() int {
+	l.Lock()
+	defer l.Unlock()
+	return l.total
+}
+
+//must be called with lock held
+func (l *launcher) pick() *launchTest {
+	weights := 0
+	for _, t := range l.tests {
+		if l.ready[t.flow] {
+			weights += t.test.GetWeight()
+		}
+	}
+	if weights == 0 {
+		return nil
+	}
+	w := l.rand.Intn(weights)
+	for _, t := range l.tests {
+		if !l.ready[t.flow] {
+			continue
+		}
+		if w < t.test.GetWeight() {
+			return t
+		}
+		w -= t.test.GetWeight()
+	}
+	return nil
+}
+
+//must be called with lock held
+func (l *launcher) fill() {
+	for l.running < l.limit {
+		if l.expired {
+			return
+		}
+		if time.Now().After(l.deadline) {
+			l.expired = true
+			Println(utils.Brown("duration " + runTimeDuration.String() + " expires, wait for running tests"))
+			return
+		}
+		t := l.pick()
+		if t == nil || t.flow.ctx.Err() != nil {
+			return
+		}
+		testCase := t.test.NthTestCase(t.launched)
+		t.launched++
+		if t.flow.AddTest(testCase) == 0 {
+			continue
+		}
+		l.running++
+		l.total++
+		status.addTotalTest(1)
+		f := t.flow
+		f.sched.submitTest(&schedJob{name: testCase.Name, group: f.build.Name, run: func() {
+			defer l.done()
+			f.runTest(testCase)
+		}})
+	}
+}
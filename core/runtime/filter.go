@@ -0,0 +1,82 @@
+package runtime
+
+import (
+	"github.com/shady831213/jarvism/core/loader"
+	"regexp"
+	"strings"
+)
+
+//regexps of -include and -exclude, can apply multi times
+type regexpListVar struct {
+	list []*regexp.Regexp
+}
+
+func (v *regexpListVar) Set(s string) error {
+	exp, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	v.list = append(v.list, exp)
+	return nil
+}
+
+func (v *regexpListVar) String() string {
+	exps := make([]string, 0)
+	for _, exp := range v.list {
+		exps = append(exps, exp.String())
+	}
+	return strings.Join(exps, " ")
+}
+
+func (v *regexpListVar) IsBoolFlag() bool {
+	return false
+}
+
+func (v *regexpListVar) match(s string) bool {
+	for _, exp := range v.list {
+		if exp.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+//-tags expression
+type tagsVar struct {
+	s    string
+	expr loader.TagExpr
+}
+
+func (v *tagsVar) Set(s string) error {
+	expr, err := loader.ParseTagExpr(s)
+	if err != nil {
+		return err
+	}
+	v.s, v.expr = s, expr
+	return nil
+}
+
+func (v *tagsVar) String() string {
+	return v.s
+}
+
+func (v *tagsVar) IsBoolFlag() bool {
+	return false
+}
+
+//test is matched by "build__group1__group2__test", from top group to test
+func filterPath(test *loader.AstTestCase) string {
+	return test.GetBuild().Name + "__" + test.GetName()
+}
+
+//test is kept if it matches any -include(or no -include), matches no -exclude and its tags match -tags
+func filterTest(test *loader.AstTestCase) bool {
+	if runTimeTags.expr != nil && !runTimeTags.expr.Match(test.GetTags()) {
+		return false
+	}
+	path := filterPath(test)
+	if len(runTimeInclude.list) > 0 && !runTimeInclude.match(path) {
+		return false
+	}
+	return !runTimeExclude.match(path)
+}
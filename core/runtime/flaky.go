@@ -0,0 +1,52 @@
+package runtime
+
+import (
+	"fmt"
+	"github.com/shady831213/jarvism/core/jobs"
+	"github.com/shady831213/jarvism/core/utils"
+	"strconv"
+	"text/tabwriter"
+)
+
+//tests of the job whose outcome changed in recent jobs, and their groups
+func printFlaky(record *jobs.JobRecord) {
+	records, err := jobs.Latest(jobs.DefaultFlakyWindow)
+	if err != nil {
+		Println(utils.LightRed("load jobs failed! " + err.Error()))
+		return
+	}
+	report := jobs.Flaky(records)
+	inJob := make(map[string]bool)
+	for _, test := range record.Tests {
+		inJob[test.TestKey()] = true
+	}
+	groups := make(map[string]bool)
+	const padding = 3
+	w := tabwriter.NewWriter(&stdout{}, 0, 0, padding, ' ', tabwriter.DiscardEmptyColumns|tabwriter.TabIndent|tabwriter.StripEscape|tabwriter.Debug)
+	title := false
+	for _, test := range report.Tests {
+		if !inJob[test.TestKey()] {
+			continue
+		}
+		if !title {
+			fmt.Fprintln(w, utils.Brown("Flaky Tests of jobId "+record.JobId+" in last "+strconv.Itoa(len(report.Jobs))+" jobs:"))
+			fmt.Fprintln(w, utils.Brown("SCORE\tFLIPS\tSAME_SEED_FLIPS\tRUNS\tFAILS\tTEST\t"))
+			title = true
+		}
+		fmt.Fprintln(w, fmt.Sprintf("%.2f", test.Score)+"\t"+strconv.Itoa(test.Flips)+"\t"+strconv.Itoa(test.SameSeedFlips)+"\t"+strconv.Itoa(test.Runs)+"\t"+strconv.Itoa(test.Fails)+"\t"+test.Key+"\t")
+		for _, group := range test.Groups {
+			groups[group] = true
+		}
+	}
+	if title {
+		w.Flush()
+		fmt.Fprintln(w, utils.Brown("Flaky Groups of jobId "+record.JobId+":"))
+		fmt.Fprintln(w, utils.Brown("SCORE\tFLAKY_TESTS\tTESTS\tGROUP\t"))
+		for _, group := range report.Groups {
+			if groups[group.Group] {
+				fmt.Fprintln(w, fmt.Sprintf("%.2f", group.Score)+"\t"+strconv.Itoa(group.FlakyTests)+"\t"+strconv.Itoa(group.Tests)+"\t"+group.Group+"\t")
+			}
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,188 @@
+package runtime
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//split "-vh -test_phase main" to ["-vh", "-test_phase main"]
+func splitListArgs(fields []string) []string {
+	args := make([]string, 0)
+	for _, field := range fields {
+		if strings.HasPrefix(field, "-") || len(args) == 0 {
+			args = append(args, field)
+			continue
+		}
+		args[len(args)-1] += " " + field
+	}
+	return args
+}
+
+//build test [seed] [args...], seed is optional
+func parseListFields(fields []string) (*testEntry, error) {
+	if len(fields) < 2 || fields[0] == "" || fields[1] == "" {
+		return nil, errors.New("expect build test [seed] [args...] but get \"" + strings.Join(fields, " ") + "\"!")
+	}
+	e := &testEntry{fields[0], fields[1], 0, false, []string{}}
+	fields = fields[2:]
+	if len(fields) > 0 && !strings.HasPrefix(fields[0], "-") {
+		if fields[0] != "" {
+			seed, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, errors.New("invalid seed " + fields[0] + " of test " + e.test + "!")
+			}
+			e.seed, e.seeded = seed, true
+		}
+		fields = fields[1:]
+	}
+	e.args = splitListArgs(fields)
+	return e, nil
+}
+
+//one test per line, "#" starts a comment
+func parseTextList(content string) ([]*testEntry, error) {
+	entries := make([]*testEntry, 0)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(strings.SplitN(line, "#", 2)[0])
+		if len(fields) == 0 {
+			continue
+		}
+		e, err := parseListFields(fields)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+//build,test,seed,arg1,arg2..., header line beginning with "build" is skipped
+func parseCsvList(content string) ([]*testEntry, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*testEntry, 0)
+	for i, record := range records {
+		if i == 0 && strings.TrimSpace(record[0]) == "build" {
+			continue
+		}
+		fields := make([]string, 0)
+		for j, field := range record {
+			field = strings.TrimSpace(field)
+			//empty seed column is kept as no seed, empty arg columns are dropped
+			if field != "" || j < 3 {
+				fields = append(fields, field)
+			}
+		}
+		e, err := parseListFields(fields)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+//a list of plain text lines or maps:
+//  - build1 test1 1 -vh
+//  - build: build1
+//    test: test2
+//    seed: 2
+//    args: [-vh]
+func parseYamlList(content string) ([]*testEntry, error) {
+	items := make([]interface{}, 0)
+	if err := yaml.Unmarshal([]byte(content), &items); err != nil {
+		return nil, err
+	}
+	entries := make([]*testEntry, 0)
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			e, err := parseListFields(strings.Fields(v))
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		case map[interface{}]interface{}:
+			for k := range v {
+				if key, _ := k.(string); key != "build" && key != "test" && key != "seed" && key != "args" {
+					return nil, fmt.Errorf("unknown keyword %v, valid keywords are [build, test, seed, args]!", k)
+				}
+			}
+			build, _ := v["build"].(string)
+			test, _ := v["test"].(string)
+			if build == "" || test == "" {
+				return nil, fmt.Errorf("build and test must be assigned but get %v!", v)
+			}
+			e := &testEntry{build, test, 0, false, []string{}}
+			if seed, ok := v["seed"]; ok {
+				if e.seed, ok = seed.(int); !ok {
+					return nil, fmt.Errorf("invalid seed %v of test %s!", seed, test)
+				}
+				e.seeded = true
+			}
+			switch args := v["args"].(type) {
+			case nil:
+			case string:
+				e.args = splitListArgs(strings.Fields(args))
+			case []interface{}:
+				for _, arg := range args {
+					e.args = append(e.args, fmt.Sprint(arg))
+				}
+			default:
+				return nil, fmt.Errorf("expect a list of args but get %T of test %s!", args, test)
+			}
+			entries = append(entries, e)
+		default:
+			return nil, fmt.Errorf("expect a line or map but get %T!", item)
+		}
+	}
+	return entries, nil
+}
+
+//load test list, format is decided by extension: .yaml/.yml, .csv, others are plain text
+func loadTestList(file string) ([]*testEntry, error) {
+	file = os.ExpandEnv(file)
+	bytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*testEntry
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		entries, err = parseYamlList(string(bytes))
+	case ".csv":
+		entries, err = parseCsvList(string(bytes))
+	default:
+		entries, err = parseTextList(string(bytes))
+	}
+	if err != nil {
+		return nil, errors.New("parse " + file + " error: " + err.Error())
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no test in " + file + "!")
+	}
+	return entries, nil
+}
+
+//run tests in list file in one job, each line is "build test [seed] [args...]"
+func RunList(file string, args []string, sc chan os.Signal) error {
+	entries, err := loadTestList(file)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	return runTestList(name, entries, args, sc)
+}
@@ -1,15 +1,33 @@
 package runtime
 
 import (
+	"errors"
 	"github.com/shady831213/jarvism/core/options"
 	"github.com/shady831213/jarvism/core/plugin"
 	"github.com/shady831213/jarvism/core/utils"
 	"strings"
+	"time"
 )
 
 var runTimeMaxJob int
+var runTimeMaxBuildJob int
+var runTimeMaxTestJob int
+var runTimeSchedPolicy schedPolicyVar
 var runTimeSimOnly bool
 var runTimeUnique bool
+var runTimeTimeout time.Duration
+var runTimeRetry int
+var runTimeMasterSeed int
+var runTimeShard shardVar
+var runTimeInclude regexpListVar
+var runTimeExclude regexpListVar
+var runTimeTags tagsVar
+var runTimeSample int
+var runTimeDuration time.Duration
+var runTimeResume string
+var runTimeMaxFail int
+var runTimeMaxFailRate float64
+var runTimeStopOnBuildFail bool
 var runTimeReporter = &runTimeReporterVar{}
 
 type runTimeReporterVar struct {
@@ -51,8 +69,39 @@ func (v *runTimeReporterVar) IsBoolFlag() bool {
 }
 
 func init() {
-	options.GetJvsOptions().IntVar(&runTimeMaxJob, "max_job", -1, "limit of runtime coroutines, default is unlimited.")
+	options.GetJvsOptions().IntVar(&runTimeMaxJob, "max_job", -1, "limit of running builds and running tests respectively if -max_build_job or -max_test_job is not set, default is unlimited.")
+	options.GetJvsOptions().IntVar(&runTimeMaxBuildJob, "max_build_job", -1, "limit of running builds, default is -max_job.")
+	options.GetJvsOptions().IntVar(&runTimeMaxTestJob, "max_test_job", -1, "limit of running tests, default is -max_job.")
+	options.GetJvsOptions().Var(&runTimeSchedPolicy, "sched_policy", "order of dispatching queued builds and tests, "+strings.Join(schedPolicies, " or ")+". name: in name order; round_robin: tests take turns between builds. default is name.")
 	options.GetJvsOptions().BoolVar(&runTimeSimOnly, "sim_only", false, "bypass compile and only run simulation, default is false.")
 	options.GetJvsOptions().BoolVar(&runTimeUnique, "unique", false, "if set jobId(timestamp) will be included in hash, then builds and testcases will have unique name and be in unique dir.default is false.")
+	options.GetJvsOptions().DurationVar(&runTimeTimeout, "timeout", 0, "wall-clock limit of each build and test which has no timeout configured, e.g. 30m, default is unlimited.")
+	options.GetJvsOptions().IntVar(&runTimeRetry, "retry", 0, "retry failed, unknown and timeout tests which have no retry configured n times with the same seed, default is 0.")
+	options.GetJvsOptions().IntVar(&runTimeMasterSeed, "master_seed", 0, "derive seeds of all tests from it, the same master seed regenerates the same seeds, default is random.")
+	options.GetJvsOptions().Var(&runTimeShard, "shard", "run the ith of n shards of tests, e.g. 2/4, builds are only run if tests of the shard need them, must work with -master_seed, default is all tests.")
+	options.GetJvsOptions().Var(&runTimeInclude, "include", "only run tests whose \"build__group1__group2__test\" path matches the regexp, can apply multi times, default is all tests.")
+	options.GetJvsOptions().Var(&runTimeExclude, "exclude", "not run tests whose \"build__group1__group2__test\" path matches the regexp, can apply multi times.")
+	options.GetJvsOptions().Var(&runTimeTags, "tags", "only run tests whose tags match the expression, e.g. \"smoke && !long\", \"(ddr || pcie) && !long\".")
+	options.GetJvsOptions().IntVar(&runTimeSample, "sample", 0, "randomly pick n runs of tests, tests are picked in proportion to their weight and get a new seed each time they are picked, default is all tests.")
+	options.GetJvsOptions().DurationVar(&runTimeDuration, "duration", 0, "keep launching new seeds of tests in proportion to their weight until time budget expires, e.g. 8h, then wait for running tests and report, -seed and -repeat are ignored, can't work with -shard and -resume.")
+	options.GetJvsOptions().StringVar(&runTimeResume, "resume", "", "resume an interrupted job by jobId, reuse passed builds and finished tests of it and only run the rest.")
+	options.GetJvsOptions().IntVar(&runTimeMaxFail, "max_fail", -1, "cancel the rest of job once number of failed tests exceeds it, not started tests are skipped, default is unlimited.")
+	options.GetJvsOptions().Float64Var(&runTimeMaxFailRate, "max_fail_rate", -1, "cancel the rest of job once failed tests exceed the percentage of all tests, e.g. 10 for 10%, not started tests are skipped, default is unlimited.")
+	options.GetJvsOptions().BoolVar(&runTimeStopOnBuildFail, "stop_on_build_fail", false, "cancel the rest of job once a build fails, not started builds and tests are skipped, default is false.")
 	options.GetJvsOptions().Var(runTimeReporter, "reporter", "add reporter plugin, can apply multi times, default")
 }
+
+//tests of -duration are launched on the fly, they can't be sharded or resumed.
+//Shards are dealt over seeds, so all hosts must derive the same seeds from the same -master_seed.
+func checkOptions() error {
+	if runTimeShard.enabled() && runTimeMasterSeed == 0 {
+		return errors.New("-shard " + runTimeShard.String() + " must work with -master_seed, otherwise shards of hosts overlap!")
+	}
+	if runTimeDuration > 0 && runTimeShard.enabled() {
+		return errors.New("-duration can't work with -shard " + runTimeShard.String() + "!")
+	}
+	if runTimeDuration > 0 && runTimeResume != "" {
+		return errors.New("-duration can't work with -resume " + runTimeResume + "!")
+	}
+	return nil
+}
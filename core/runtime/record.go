@@ -0,0 +1,131 @@
+package runtime
+
+import (
+	"github.com/shady831213/jarvism/core/errors"
+	"github.com/shady831213/jarvism/core/jobs"
+	"github.com/shady831213/jarvism/core/loader"
+	"github.com/shady831213/jarvism/core/utils"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//build-in reporter, save results of job to job store
+type jobRecorder struct {
+	r      *runTime
+	record *jobs.JobRecord
+}
+
+func newJobRecorder(r *runTime) *jobRecorder {
+	return &jobRecorder{r: r}
+}
+
+func (j *jobRecorder) Name() string {
+	return "jobRecorder"
+}
+
+//save plan at beginning, so that a broken job can be resumed
+func (j *jobRecorder) Init(jobId string, totalBuild, totalTest int) {
+	if j.r.resume != nil {
+		j.record = j.r.resume.record
+	} else {
+		j.record = jobs.NewJobRecord(jobId, j.r.Name, j.r.args)
+		j.record.MasterSeed = j.r.masterSeed
+		j.record.Shard = runTimeShard.String()
+		for _, f := range j.r.runFlow {
+			for _, test := range f.testCases {
+				record := new(jobs.ResultRecord)
+				record.Name = test.Name
+				j.testRecord(record, test)
+				j.record.Plan = append(j.record.Plan, record)
+			}
+		}
+		sort.Slice(j.record.Plan, func(i, k int) bool {
+			return j.record.Plan[i].Name < j.record.Plan[k].Name
+		})
+	}
+	j.record.LogFile = j.r.logFile
+	if _, err := jobs.Save(j.record); err != nil {
+		Println(utils.LightRed("save job " + j.record.JobId + " failed! " + err.Error()))
+	}
+}
+
+//runner report dir as "path:xxx" in msgs
+func resultLogDir(result *jobs.ResultRecord) string {
+	for _, msg := range result.Msgs {
+		for _, line := range strings.Split(msg, "\n") {
+			if strings.HasPrefix(line, "path:") {
+				return strings.TrimSpace(strings.TrimPrefix(line, "path:"))
+			}
+		}
+	}
+	return ""
+}
+
+//record of planned test before it runs
+func (j *jobRecorder) testRecord(record *jobs.ResultRecord, test *loader.AstTestCase) {
+	record.Build = test.Identity.Build
+	record.BuildHash = test.Identity.BuildHash
+	record.Groups = test.Identity.Groups
+	record.Test = test.Identity.Test
+	record.Seed = test.Identity.Seed
+	record.Args = test.GetArgs()
+}
+
+//results collected after interrupted are not real results, except pass
+func (j *jobRecorder) newRecord(result *errors.JVSRuntimeResult) *jobs.ResultRecord {
+	record := jobs.NewResultRecord(result)
+	record.LogDir = resultLogDir(record)
+	record.Interrupted = atomic.LoadInt32(&j.r.interrupted) == 1 && result.Status != errors.JVSRuntimePass
+	return record
+}
+
+func (j *jobRecorder) CollectBuildResult(result *errors.JVSRuntimeResult) {
+	if j.r.resume != nil {
+		if record, ok := j.r.resume.builds[result.Name]; ok {
+			j.record.Builds = append(j.record.Builds, record)
+			return
+		}
+	}
+	record := j.newRecord(result)
+	j.record.Builds = append(j.record.Builds, record)
+}
+
+func (j *jobRecorder) CollectTestResult(result *errors.JVSRuntimeResult) {
+	if j.r.resume != nil {
+		if record, ok := j.r.resume.tests[result.Identity.Key()]; ok {
+			//waived after resumed
+			if record.GetStatus() != result.Status {
+				waived := *record
+				waived.Status = errors.StatusString(result.Status)
+				waived.Msgs = result.GetMsgs()
+				record = &waived
+			}
+			j.record.Tests = append(j.record.Tests, record)
+			return
+		}
+	}
+	record := j.newRecord(result)
+	if _, test := j.r.findTest(record.PlanKey()); test != nil {
+		record.Args = test.GetArgs()
+	}
+	j.record.Tests = append(j.record.Tests, record)
+}
+
+func (j *jobRecorder) Report() {
+	j.record.EndTime = time.Now()
+	j.record.Interrupted = atomic.LoadInt32(&j.r.interrupted) == 1
+	if reason, ok := j.r.stopReason.Load().(string); ok {
+		j.record.StopReason = reason
+	}
+	j.record.Signatures = jobs.Triage(j.record.Tests)
+	printSignatures(j.record.JobId, j.record.Signatures)
+	file, err := jobs.Save(j.record)
+	if err != nil {
+		Println(utils.LightRed("save job " + j.record.JobId + " failed! " + err.Error()))
+		return
+	}
+	Println(utils.Brown("job " + j.record.JobId + " is saved in " + file))
+	printFlaky(j.record)
+}
@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"errors"
+	jvsErrors "github.com/shady831213/jarvism/core/errors"
+	"github.com/shady831213/jarvism/core/jobs"
+	"github.com/shady831213/jarvism/core/options"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//a test with specific build, seed and args, seeded is false if seed is not assigned
+type testEntry struct {
+	build  string
+	test   string
+	seed   int
+	seeded bool
+	args   []string
+}
+
+//args of test in config, seed 0 is a valid seed if it is assigned
+func (e *testEntry) testArgs() []interface{} {
+	testArgs := make([]interface{}, 0)
+	for _, arg := range e.args {
+		testArgs = append(testArgs, arg)
+	}
+	if e.seeded {
+		testArgs = append(testArgs, "-seed "+strconv.Itoa(e.seed))
+	}
+	return testArgs
+}
+
+//run heterogeneous tests in one job, tests are put in an ad-hoc group as RunTest does
+func runTestList(name string, entries []*testEntry, args []string, sc chan os.Signal) error {
+	tests := make([]interface{}, 0)
+	for _, e := range entries {
+		tests = append(tests, map[interface{}]interface{}{e.test: map[interface{}]interface{}{"build": e.build, "args": e.testArgs()}})
+	}
+	return run(name, args, map[interface{}]interface{}{"args": filterAstArgs(args), "tests": tests}, sc)
+}
+
+func argName(arg string) string {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return ""
+	}
+	name, _ := options.ArgToOption(fields[0])
+	return strings.SplitN(name, "=", 2)[0]
+}
+
+//options selecting, seeding and scheduling tests of the original job, recorded tests are rerun as they are
+func rerunDropped() map[string]bool {
+	return map[string]bool{"repeat": true, "seed": true, "seeds_file": true, "tags": true, "include": true, "exclude": true,
+		"shard": true, "sample": true, "duration": true, "resume": true}
+}
+
+func dropArgs(args []string, dropped map[string]bool) []string {
+	kept := make([]string, 0)
+	for _, arg := range args {
+		if !dropped[argName(arg)] {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+//args of record except seeds and args overridden by cmdline
+func rerunArgs(record *jobs.ResultRecord, args []string) []string {
+	overridden := rerunDropped()
+	for _, arg := range args {
+		overridden[argName(arg)] = true
+	}
+	return dropArgs(record.Args, overridden)
+}
+
+//rerun tests of job jobId whose status in statuses, with the same build, seed and args.
+//
+//args are appended to args of the job, args selecting tests of the job, e.g. -tags, -shard and -seeds_file, are dropped.
+func RerunJob(jobId string, statuses []jvsErrors.JVSRuntimeStatus, args []string, sc chan os.Signal) error {
+	record, err := jobs.Load(jobId)
+	if err != nil {
+		return err
+	}
+	selected := make(map[jvsErrors.JVSRuntimeStatus]bool)
+	for _, s := range statuses {
+		selected[s] = true
+	}
+	entries := make([]*testEntry, 0)
+	for _, test := range record.Tests {
+		if !selected[test.GetStatus()] || test.Test == "" {
+			continue
+		}
+		entries = append(entries, &testEntry{test.Build, test.Test, test.Seed, true, rerunArgs(test, args)})
+	}
+	if len(entries) == 0 {
+		return errors.New("no test to rerun in job " + jobId + "!")
+	}
+	jobArgs := make([]string, 0)
+	jobArgs = append(jobArgs, dropArgs(record.Args, rerunDropped())...)
+	jobArgs = append(jobArgs, args...)
+	return runTestList(jobId, entries, jobArgs, sc)
+}
@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"github.com/shady831213/jarvism/core/errors"
+	"github.com/shady831213/jarvism/core/jobs"
+	"sort"
+	"strings"
+	"time"
+)
+
+//state of resumed job
+//
+//builds: passed builds, they are reused
+//
+//tests: finished tests by ResultRecord.Key, they are not run again, skipped tests are not finished
+//
+//seeds: seeds of unfinished tests by ResultRecord.TestKey, build hash is not in key, so that tests whose build hash changed still resume
+type resumeState struct {
+	id     string
+	record *jobs.JobRecord
+	builds map[string]*jobs.ResultRecord
+	tests  map[string]*jobs.ResultRecord
+	seeds  map[string][]int
+}
+
+func loadResume(jobId string) (*resumeState, error) {
+	if jobId == "" {
+		return nil, nil
+	}
+	record, err := jobs.Load(jobId)
+	if err != nil {
+		return nil, err
+	}
+	s := new(resumeState)
+	s.id = strings.Replace(time.Now().Format("20060102_150405.0000"), ".", "", 1)
+	s.record = record
+	s.builds = make(map[string]*jobs.ResultRecord)
+	s.tests = make(map[string]*jobs.ResultRecord)
+	s.seeds = make(map[string][]int)
+	for _, build := range record.Builds {
+		if build.GetStatus() == errors.JVSRuntimePass && !build.Interrupted {
+			s.builds[build.Name] = build
+		}
+	}
+	planned := make(map[string]bool)
+	for _, test := range record.Tests {
+		if !test.Interrupted && test.GetStatus() != errors.JVSRuntimeSkipped {
+			s.tests[test.Key()] = test
+			planned[test.PlanKey()] = true
+		}
+	}
+	for _, test := range record.Plan {
+		if !planned[test.Key()] {
+			s.seeds[test.TestKey()] = append(s.seeds[test.TestKey()], test.Seed)
+		}
+	}
+	//results are collected again when job resumes
+	record.Builds = make([]*jobs.ResultRecord, 0)
+	record.Tests = make([]*jobs.ResultRecord, 0)
+	record.Interrupted = false
+	record.StopReason = ""
+	return s, nil
+}
+
+//seeds of unfinished tests, empty if all finished or test is not in the job
+func (s *resumeState) pendingSeeds(key string) []int {
+	if seeds, ok := s.seeds[key]; ok {
+		return seeds
+	}
+	return make([]int, 0)
+}
+
+//mark passed builds as built, return number of finished tests
+func (s *resumeState) reuse(r *runTime) int {
+	for _, f := range r.runFlow {
+		if _, ok := s.builds[f.build.Name]; ok {
+			f.built = true
+		}
+	}
+	return len(s.tests)
+}
+
+//report results of reused builds and finished tests, then reporters get the merged results.
+//They are collected as new results, so that waivers and -max_fail apply to them too.
+func (s *resumeState) feed(r *runTime) {
+	for _, f := range r.runFlow {
+		if build, ok := s.builds[f.build.Name]; ok {
+			result := build.Result()
+			result.Identity.JobId = r.runtimeId
+			r.collectBuildResult(result)
+		}
+	}
+	keys := make([]string, 0)
+	for key := range s.tests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result := s.tests[key].Result()
+		result.Identity.JobId = r.runtimeId
+		r.collectTestResult(result)
+	}
+}
+
+func (r *runTime) logId() string {
+	if r.resume != nil {
+		return r.runtimeId + "_resume_" + r.resume.id
+	}
+	return r.runtimeId
+}
@@ -5,9 +5,9 @@ According to ast, generate runflow.
 
 Runflow includes 1 build and multi testcases.
 
-All runflows run in parallel, all testcases in 1 runflow run in parallel.
+Builds of all runflows and testcases run in parallel, limited by scheduler.
 
-In 1 runflow, all testcases start after build done.
+In 1 runflow, all testcases are submitted to scheduler after build done.
 */
 
 package runtime
@@ -20,69 +20,64 @@ import (
 	"github.com/shady831213/jarvism/core/loader"
 	"github.com/shady831213/jarvism/core/utils"
 	"io"
-	"math"
-	"math/big"
 	"os"
 	"os/exec"
 	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
+//the first 8 bytes of sha256 in hex
 func hashFunc(s string) string {
-	h := new(big.Int).SetBytes(sha256.New().Sum(([]byte)(s)))
-	mb := big.NewInt(math.MaxInt64)
-	h.Mod(h, mb)
-	return hex.EncodeToString(h.Bytes())
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
 }
 
-type runTimeJobLimiter struct {
-	maxJob chan bool
-}
-
-func (l *runTimeJobLimiter) put() {
-	if l.maxJob != nil {
-		l.maxJob <- true
-	}
-}
-
-func (l *runTimeJobLimiter) get() {
-	if l.maxJob != nil {
-		<-l.maxJob
-	}
-}
-
-func (l *runTimeJobLimiter) close() {
-	if l.maxJob != nil {
-		close(l.maxJob)
-	}
-}
-
-var runTimeLimiter runTimeJobLimiter
-
 func runTimeFinish() {
-	runTimeLimiter.close()
 	runTimeMaxJob = -1
+	runTimeMaxBuildJob = -1
+	runTimeMaxTestJob = -1
+	runTimeSchedPolicy = schedPolicyVar{}
 	runTimeSimOnly = false
 	runTimeUnique = false
+	runTimeTimeout = 0
+	runTimeRetry = 0
+	runTimeMasterSeed = 0
+	runTimeShard = shardVar{}
+	runTimeInclude = regexpListVar{}
+	runTimeExclude = regexpListVar{}
+	runTimeTags = tagsVar{}
+	runTimeSample = 0
+	runTimeDuration = 0
+	runTimeResume = ""
+	runTimeMaxFail = -1
+	runTimeMaxFailRate = -1
+	runTimeStopOnBuildFail = false
 }
 
 type runFlow struct {
+	//testCases are keyed by JVSRuntimeIdentity.Key, with -duration they are added by launcher after build done
+	sync.Mutex
 	build     *loader.AstBuild
+	built     bool
 	testCases map[string]*loader.AstTestCase
-	testWg    sync.WaitGroup
+	sched     *scheduler
 	cmdStdout *io.Writer
 	buildDone chan *errors.JVSRuntimeResult
 	testDone  chan *errors.JVSRuntimeResult
 	ctx       context.Context
+	launcher  *launcher
 }
 
-func newRunFlow(build *loader.AstBuild, cmdStdout *io.Writer, buildDone chan *errors.JVSRuntimeResult, testDone chan *errors.JVSRuntimeResult, ctx context.Context) *runFlow {
+func newRunFlow(build *loader.AstBuild, sched *scheduler, cmdStdout *io.Writer, buildDone chan *errors.JVSRuntimeResult, testDone chan *errors.JVSRuntimeResult, ctx context.Context) *runFlow {
 	inst := new(runFlow)
 	inst.build = build
-	inst.testWg = sync.WaitGroup{}
+	inst.sched = sched
 	inst.cmdStdout = cmdStdout
 	inst.testCases = make(map[string]*loader.AstTestCase)
 	inst.buildDone = buildDone
@@ -95,32 +90,56 @@ type phase func() *errors.JVSRuntimeResult
 
 func preparePhase(phaseName string, p phase) *errors.JVSRuntimeResult {
 	PrintStatus(phaseName, utils.Blue("BEGIN"))
+	startTime := time.Now()
 	result := p()
 	if result == nil {
 		result = errors.JVSRuntimeResultUnknown("No Result!")
-		PrintStatus(phaseName, result.Error())
-		return result
 	}
+	result.AddPhase("prepare", startTime, time.Now())
 	if result.Status != errors.JVSRuntimePass {
-		PrintStatus(phaseName, result.Error())
+		PrintStatus(phaseName, result.PhasesError())
 	}
 	return result
 }
 
-func runPhase(phaseName string, p phase) *errors.JVSRuntimeResult {
+//phases of prepared are prior to phases of p
+func runPhase(phaseName string, prepared *errors.JVSRuntimeResult, p phase) *errors.JVSRuntimeResult {
 	result := p()
 	if result == nil {
 		result = errors.JVSRuntimeResultUnknown("No Result!")
-		PrintStatus(phaseName, result.Error())
-		return result
 	}
-	PrintStatus(phaseName, result.Error())
+	result.Phases = append(append([]*errors.JVSRuntimePhase{}, prepared.Phases...), result.Phases...)
+	PrintStatus(phaseName, result.PhasesError())
 	return result
 }
 
-func (f *runFlow) cmdRunner(checkerPipeWriter io.WriteCloser) loader.CmdRunner {
+//phase context with timeout, if timeout is not configured, use -timeout
+func (f *runFlow) phaseContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = runTimeTimeout
+	}
+	if timeout > 0 {
+		return context.WithTimeout(f.ctx, timeout)
+	}
+	return context.WithCancel(f.ctx)
+}
+
+//kill the whole process group, make sure children of the hung process are killed too
+func killCmd(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return
+	}
+	cmd.Process.Kill()
+}
+
+func (f *runFlow) cmdRunner(ctx context.Context, checkerPipeWriter io.WriteCloser) loader.CmdRunner {
 	return func(attr *loader.CmdAttr, name string, arg ...string) (res *errors.JVSRuntimeResult) {
-		cmd := exec.CommandContext(f.ctx, name, arg...)
+		cmd := exec.Command(name, arg...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 		closers := make([]io.Closer, 0)
 		defer func() {
 			for _, c := range closers {
@@ -158,7 +177,26 @@ func (f *runFlow) cmdRunner(checkerPipeWriter io.WriteCloser) loader.CmdRunner {
 				return errors.JVSRuntimeResultUnknown(err.Error())
 			}
 		}
-		if err := cmd.Run(); err != nil {
+		if err := ctx.Err(); err != nil {
+			return errors.JVSRuntimeResultUnknown(err.Error())
+		}
+		if err := cmd.Start(); err != nil {
+			return errors.JVSRuntimeResultUnknown(stderr.Msg+"\n", err.Error())
+		}
+		//kill when canceled or timeout
+		cmdDone := make(chan bool)
+		defer close(cmdDone)
+		go func() {
+			select {
+			case <-ctx.Done():
+				killCmd(cmd)
+			case <-cmdDone:
+			}
+		}()
+		if err := cmd.Wait(); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.JVSRuntimeResultTimeout(stderr.Msg+"\n", err.Error())
+			}
 			return errors.JVSRuntimeResultUnknown(stderr.Msg+"\n", err.Error())
 		}
 		return errors.JVSRuntimeResultPass("")
@@ -167,19 +205,19 @@ func (f *runFlow) cmdRunner(checkerPipeWriter io.WriteCloser) loader.CmdRunner {
 
 func (f *runFlow) prepareBuildPhase(build *loader.AstBuild) *errors.JVSRuntimeResult {
 	return preparePhase(build.Name, func() *errors.JVSRuntimeResult {
-		return loader.GetCurRunner().PrepareBuild(build, f.cmdRunner(nil))
+		return loader.GetCurRunner().PrepareBuild(build, f.cmdRunner(f.ctx, nil))
 	})
 }
 
-func (f *runFlow) checkPhase(checker loader.Checker) (*io.PipeWriter, func(), chan *errors.JVSRuntimeResult) {
+func (f *runFlow) checkPhase(ctx context.Context, checker loader.Checker) (*io.PipeWriter, func(), chan *errors.JVSRuntimeResult) {
 	rd, wr := io.Pipe()
 	checker.Input(rd)
 	done := make(chan *errors.JVSRuntimeResult)
 	goroutine := func() {
 		defer close(done)
 		select {
-		case <-f.ctx.Done():
-			done <- errors.JVSRuntimeResultUnknown("context canceled!")
+		case <-ctx.Done():
+			done <- errors.JVSRuntimeResultUnknown(ctx.Err().Error())
 		case done <- checker.Check():
 			return
 		}
@@ -187,104 +225,224 @@ func (f *runFlow) checkPhase(checker loader.Checker) (*io.PipeWriter, func(), ch
 	return wr, goroutine, done
 }
 
-func (f *runFlow) buildPhase(build *loader.AstBuild) *errors.JVSRuntimeResult {
-	return runPhase(build.Name, func() *errors.JVSRuntimeResult {
-		wr, check, done := f.checkPhase(build.GetChecker())
+func timeoutStatus(ctx context.Context, status errors.JVSRuntimeStatus) (errors.JVSRuntimeStatus, string) {
+	if ctx.Err() == context.DeadlineExceeded {
+		deadline, _ := ctx.Deadline()
+		return errors.JVSRuntimeTimeout, "killed at " + deadline.Format("2006-01-02 15:04:05") + "!"
+	}
+	return status, ""
+}
+
+func (f *runFlow) buildPhase(build *loader.AstBuild, prepared *errors.JVSRuntimeResult) *errors.JVSRuntimeResult {
+	return runPhase(build.Name, prepared, func() *errors.JVSRuntimeResult {
+		ctx, cancel := f.phaseContext(build.GetTimeout())
+		defer cancel()
+		wr, check, done := f.checkPhase(ctx, build.GetChecker())
 		go check()
 		status := errors.JVSRuntimePass
-		execRes := loader.GetCurRunner().Build(build, f.cmdRunner(wr))
+		execStart := time.Now()
+		execRes := loader.GetCurRunner().Build(build, f.cmdRunner(ctx, wr))
 		if execRes.Status > status {
 			status = execRes.Status
 		}
+		checkStart := time.Now()
 		checkRes := <-done
 		if checkRes.Status > status {
 			status = checkRes.Status
 		}
-		return errors.NewJVSRuntimeResult(status, checkRes.GetMsg()+"\n", execRes.GetMsg())
+		status, timeoutMsg := timeoutStatus(ctx, status)
+		result := errors.NewJVSRuntimeResult(status, timeoutMsg, checkRes.GetMsg()+"\n", execRes.GetMsg())
+		result.AddPhase("build", execStart, checkStart)
+		result.AddPhase("check", checkStart, time.Now())
+		return result
 	})
 }
 
 func (f *runFlow) prepareTestPhase(testCase *loader.AstTestCase) *errors.JVSRuntimeResult {
 	return preparePhase(testCase.Name, func() *errors.JVSRuntimeResult {
-		return loader.GetCurRunner().PrepareTest(testCase, f.cmdRunner(nil))
+		return loader.GetCurRunner().PrepareTest(testCase, f.cmdRunner(f.ctx, nil))
 	})
 }
 
-func (f *runFlow) runTestPhase(testCase *loader.AstTestCase) *errors.JVSRuntimeResult {
-	return runPhase(testCase.Name, func() *errors.JVSRuntimeResult {
-		wr, check, done := f.checkPhase(testCase.GetChecker())
+func (f *runFlow) runTestPhase(testCase *loader.AstTestCase, prepared *errors.JVSRuntimeResult) *errors.JVSRuntimeResult {
+	return runPhase(testCase.Name, prepared, func() *errors.JVSRuntimeResult {
+		ctx, cancel := f.phaseContext(testCase.GetTimeout())
+		defer cancel()
+		wr, check, done := f.checkPhase(ctx, testCase.GetChecker())
 		go check()
 		status := errors.JVSRuntimePass
-		execRes := loader.GetCurRunner().RunTest(testCase, f.cmdRunner(wr))
+		execStart := time.Now()
+		execRes := loader.GetCurRunner().RunTest(testCase, f.cmdRunner(ctx, wr))
 		if execRes.Status > status {
 			status = execRes.Status
 		}
+		checkStart := time.Now()
 		checkRes := <-done
 		if checkRes.Status > status {
 			status = checkRes.Status
 		}
-		return errors.NewJVSRuntimeResult(status, checkRes.GetMsg()+"\n", execRes.GetMsg())
+		status, timeoutMsg := timeoutStatus(ctx, status)
+		result := errors.NewJVSRuntimeResult(status, timeoutMsg, checkRes.GetMsg()+"\n", execRes.GetMsg())
+		result.AddPhase("run", execStart, checkStart)
+		result.AddPhase("check", checkStart, time.Now())
+		return result
 	})
 }
 
-func (f *runFlow) AddTest(test *loader.AstTestCase) int {
-	test.Name = f.build.Name + "__" + test.Name
+//tests run with build of flow and are named after it
+func (f *runFlow) bindTest(test *loader.AstTestCase) {
+	test.Identity.JobId, test.Identity.Build, test.Identity.BuildHash = f.build.Identity.JobId, f.build.Identity.Build, f.build.Identity.BuildHash
+	test.Name = test.Identity.String()
 	test.SetBuild(f.build)
-	if _, ok := f.testCases[test.Name]; !ok {
-		f.testCases[test.Name] = test
+}
+
+func (f *runFlow) AddTest(test *loader.AstTestCase) int {
+	f.Lock()
+	defer f.Unlock()
+	f.bindTest(test)
+	if _, ok := f.testCases[test.Identity.Key()]; !ok {
+		f.testCases[test.Identity.Key()] = test
 		return 1
 	}
 	return 0
 }
 
+func skippedResult(id *errors.JVSRuntimeIdentity, reason string, msgs ...string) *errors.JVSRuntimeResult {
+	result := errors.JVSRuntimeResultSkipped(append([]string{reason}, msgs...)...)
+	result.Name = id.String()
+	result.Identity = id
+	return result
+}
+
+//every test gets a result even if it never runs
+func (f *runFlow) skipTests(reason string, msgs ...string) {
+	for _, test := range f.testCases {
+		f.testDone <- skippedResult(&test.Identity, reason, msgs...)
+	}
+}
+
+//tests are skipped as cancelled if build is killed by cancelling job, they don't count as failures of build
+func (f *runFlow) buildNotPassed(result *errors.JVSRuntimeResult) {
+	f.buildDone <- result
+	if f.ctx.Err() != nil {
+		f.skipTests(errors.JVSRuntimeSkipCancelled)
+		return
+	}
+	f.skipTests(errors.JVSRuntimeSkipBuildFailed, f.build.Name+" is "+errors.StatusString(result.Status)+"!")
+}
+
+//build job, tests are submitted to scheduler after build done
 func (f *runFlow) run() {
-	//run compile
-	if !runTimeSimOnly {
+	//job is cancelled before flow starts
+	if f.ctx.Err() != nil {
+		if !runTimeSimOnly && !f.built {
+			f.buildDone <- skippedResult(&f.build.Identity, errors.JVSRuntimeSkipCancelled)
+		}
+		f.skipTests(errors.JVSRuntimeSkipCancelled)
+		return
+	}
+	//run compile, build passed in resumed job is reused
+	if !runTimeSimOnly && !f.built {
+		startTime := time.Now()
 		result := f.prepareBuildPhase(f.build)
 		result.Name = f.build.Name
+		result.Identity = &f.build.Identity
+		result.StartTime, result.EndTime = startTime, time.Now()
 		if result.Status != errors.JVSRuntimePass {
-			f.buildDone <- result
-			runTimeLimiter.get()
+			f.buildNotPassed(result)
 			return
 		}
-		result = f.buildPhase(f.build)
+		result = f.buildPhase(f.build, result)
+		result.Name = f.build.Name
+		result.Identity = &f.build.Identity
+		result.StartTime, result.EndTime = startTime, time.Now()
 		if result.Status != errors.JVSRuntimePass {
-			f.buildDone <- result
-			runTimeLimiter.get()
+			f.buildNotPassed(result)
 			return
 		}
 		f.buildDone <- result
 	}
-	runTimeLimiter.get()
+
+	//-duration, tests are launched after build done
+	if f.launcher != nil {
+		f.launcher.start(f)
+		return
+	}
 
 	//run tests
+	jobs := make([]*schedJob, 0, len(f.testCases))
 	for _, test := range f.testCases {
-		f.testWg.Add(1)
-		runTimeLimiter.put()
-		go func(testCase *loader.AstTestCase) {
-			defer f.testWg.Add(-1)
-			defer runTimeLimiter.get()
-			result := f.prepareTestPhase(testCase)
-			result.Name = testCase.Name
-			if result.Status != errors.JVSRuntimePass {
-				f.testDone <- result
-				return
-			}
-			result = f.runTestPhase(testCase)
-			f.testDone <- result
-		}(test)
+		testCase := test
+		jobs = append(jobs, &schedJob{name: testCase.Name, group: f.build.Name, run: func() { f.runTest(testCase) }})
 	}
-	f.testWg.Wait()
+	f.sched.submitTest(jobs...)
+}
+
+func (f *runFlow) runTest(testCase *loader.AstTestCase) {
+	//job is cancelled before test starts
+	if f.ctx.Err() != nil {
+		f.testDone <- skippedResult(&testCase.Identity, errors.JVSRuntimeSkipCancelled)
+		return
+	}
+	result := f.runTestAttempt(testCase)
+	//retry failing test, failed attempts are kept in final result
+	retry := testCase.GetRetry()
+	if retry == nil && runTimeRetry > 0 {
+		retry = loader.NewRetryPolicy(runTimeRetry)
+	}
+	attempts := make([]*errors.JVSRuntimeResult, 0)
+	for attempt := testCase; retry.ShouldRetry(result, len(attempts)) && f.ctx.Err() == nil; {
+		attempts = append(attempts, result)
+		if retry.NewSeed {
+			attempt = testCase.Reseed(len(attempts))
+			f.bindTest(attempt)
+		}
+		PrintStatus(testCase.Name, utils.Brown("RETRY "+strconv.Itoa(len(attempts))+"/"+strconv.Itoa(retry.Times)+" "+attempt.Name))
+		result = f.runTestAttempt(attempt)
+	}
+	//final result keeps the identity of the attempt which produced it, a test retried with a new seed is recorded with the new seed
+	if len(attempts) > 0 {
+		result.StartTime = attempts[0].StartTime
+		result.Attempts = attempts
+	}
+	f.testDone <- result
+}
+
+func (f *runFlow) runTestAttempt(testCase *loader.AstTestCase) *errors.JVSRuntimeResult {
+	startTime := time.Now()
+	result := f.prepareTestPhase(testCase)
+	result.Name = testCase.Name
+	result.Identity = &testCase.Identity
+	result.StartTime, result.EndTime = startTime, time.Now()
+	if result.Status != errors.JVSRuntimePass {
+		return result
+	}
+	result = f.runTestPhase(testCase, result)
+	result.Name = testCase.Name
+	result.Identity = &testCase.Identity
+	result.StartTime, result.EndTime = startTime, time.Now()
+	return result
 }
 
 type runTime struct {
 	cmdStdout                   io.Writer
 	reporters                   []Reporter
 	runtimeId                   string
+	masterSeed                  int
 	Name                        string
+	args                        []string
+	logFile                     string
+	resume                      *resumeState
+	interrupted                 int32
+	stopReason                  atomic.Value
+	failCnt                     int
 	totalTest                   int
 	runFlow                     map[string]*runFlow
-	flowWg                      sync.WaitGroup
+	sched                       *scheduler
+	launcher                    *launcher
+	waived                      map[*loader.Waiver]int
+	buildHashes                 map[string]string
+	filtered                    []*errors.JVSRuntimeResult
 	processingDone, monitorDone chan bool
 	buildDone                   chan *errors.JVSRuntimeResult
 	testDone                    chan *errors.JVSRuntimeResult
@@ -292,37 +450,65 @@ type runTime struct {
 	cancel                      func()
 }
 
-func newRunTime(name string, group *loader.AstGroup) *runTime {
+func newRunTime(name string, group *loader.AstGroup, resume *resumeState) *runTime {
 	r := new(runTime)
 	r.Name = name
 	r.runFlow = make(map[string]*runFlow)
+	r.buildHashes = make(map[string]string)
 	r.runtimeId = strings.Replace(time.Now().Format("20060102_150405.0000"), ".", "", 1)
-	r.flowWg = sync.WaitGroup{}
+	//resumed job keeps its jobId, then builds and tests keep their names
+	r.resume = resume
+	if resume != nil {
+		r.runtimeId = resume.record.JobId
+	}
+	r.masterSeed = loader.SetMasterSeed(runTimeMasterSeed)
+	r.sched = newScheduler(status.updateQueue)
 	r.processingDone = make(chan bool)
 	r.monitorDone = make(chan bool)
 	r.buildDone = make(chan *errors.JVSRuntimeResult, 100)
 	r.testDone = make(chan *errors.JVSRuntimeResult, 100)
 	ctx := context.Background()
 	r.ctx, r.cancel = context.WithCancel(ctx)
-	if runTimeMaxJob > 0 {
-		runTimeLimiter = runTimeJobLimiter{make(chan bool, runTimeMaxJob)}
-	} else {
-		runTimeLimiter = runTimeJobLimiter{nil}
-	}
 
 	testcases := group.GetTestCases()
-	r.totalTest = 0
+	selected := make([]*loader.AstTestCase, 0)
+	filtered := make([]*loader.AstTestCase, 0)
 	for _, test := range testcases {
-		r.totalTest += r.initSubTest(test)
+		if filterTest(test) {
+			selected = append(selected, test)
+		} else {
+			filtered = append(filtered, test)
+		}
+	}
+	r.totalTest = 0
+	if runTimeDuration > 0 {
+		r.launcher = newLauncher(r.masterSeed, runTimeDuration)
+		for _, test := range selected {
+			test.ParseArgs()
+			r.launcher.add(test, r.createFlow(test.GetBuild()))
+		}
+	} else if runTimeSample > 0 {
+		for test, n := range loader.SampleTestCases(selected, runTimeSample) {
+			r.totalTest += r.initSubTest(test, n)
+		}
+	} else {
+		for _, test := range selected {
+			r.totalTest += r.initSubTest(test, 0)
+		}
 	}
 	//build only
-	if r.totalTest == 0 {
+	if len(testcases) == 0 {
 		group.ParseArgs()
 		r.createFlow(group.GetBuild())
 	}
-	if r.totalTest <= 1 {
+	r.shard()
+	if r.totalTest <= 1 && r.launcher == nil {
 		r.cmdStdout = &stdout{}
 	}
+	if resume != nil {
+		r.totalTest += resume.reuse(r)
+	}
+	r.skipFiltered(filtered)
 
 	//init reporters
 	r.addReporter(runTimeReporter.getReporters()...)
@@ -338,25 +524,62 @@ func (r *runTime) addReporter(reporters ...Reporter) {
 	}
 }
 
-func (r *runTime) createFlow(build *loader.AstBuild) *runFlow {
-	var hash string
-	if runTimeUnique {
-		hash = hashFunc(r.runtimeId + build.GetRawSign())
-	} else {
-		hash = hashFunc(build.GetRawSign())
+//content of build is signed once in a job
+func (r *runTime) buildHash(build *loader.AstBuild) string {
+	hash, ok := r.buildHashes[build.GetRawSign()]
+	if !ok {
+		if runTimeUnique {
+			hash = hashFunc(r.runtimeId + build.GetSign())
+		} else {
+			hash = hashFunc(build.GetSign())
+		}
+		r.buildHashes[build.GetRawSign()] = hash
+	}
+	return hash
+}
+
+//tests filtered by -include, -exclude and -tags are reported as skipped, only by the first shard
+func (r *runTime) skipFiltered(tests []*loader.AstTestCase) {
+	if runTimeShard.enabled() && runTimeShard.index != 1 {
+		return
+	}
+	for _, test := range tests {
+		test.ParseArgs()
+		build := test.GetBuild()
+		hash := r.buildHash(build)
+		for _, t := range test.GetTestCases() {
+			id := t.Identity
+			id.JobId, id.Build, id.BuildHash = r.runtimeId, build.Name, hash
+			r.filtered = append(r.filtered, skippedResult(&id, errors.JVSRuntimeSkipFiltered))
+		}
 	}
+	r.totalTest += len(r.filtered)
+}
+
+func (r *runTime) createFlow(build *loader.AstBuild) *runFlow {
+	hash := r.buildHash(build)
 	if _, ok := r.runFlow[hash]; !ok {
 		newBuild := build.Clone()
-		newBuild.Name = r.runtimeId + "__" + build.Name + "_" + hash
-		r.runFlow[hash] = newRunFlow(newBuild, &r.cmdStdout, r.buildDone, r.testDone, r.ctx)
+		newBuild.Identity = errors.JVSRuntimeIdentity{JobId: r.runtimeId, Build: build.Name, BuildHash: hash}
+		newBuild.Name = newBuild.Identity.String()
+		r.runFlow[hash] = newRunFlow(newBuild, r.sched, &r.cmdStdout, r.buildDone, r.testDone, r.ctx)
 	}
 
 	return r.runFlow[hash]
 }
 
-func (r *runTime) initSubTest(test *loader.AstTestCase) int {
+//sampled is the times test is picked by -sample, 0 if not sampled
+func (r *runTime) initSubTest(test *loader.AstTestCase, sampled int) int {
 	test.ParseArgs()
+	if sampled > 0 {
+		test.SetSampledSeeds(sampled)
+	}
 	flow := r.createFlow(test.GetBuild())
+	if r.resume != nil {
+		path := test.GetPath()
+		id := errors.JVSRuntimeIdentity{Build: flow.build.Identity.Build, Groups: path[:len(path)-1], Test: test.Name}
+		test.SetSeeds(r.resume.pendingSeeds(id.TestKey()))
+	}
 	cnt := 0
 	for _, t := range test.GetTestCases() {
 		cnt += flow.AddTest(t)
@@ -364,20 +587,30 @@ func (r *runTime) initSubTest(test *loader.AstTestCase) int {
 	return cnt
 }
 
+//key is JVSRuntimeIdentity.Key of test
+func (r *runTime) findTest(key string) (*runFlow, *loader.AstTestCase) {
+	for _, f := range r.runFlow {
+		f.Lock()
+		test, ok := f.testCases[key]
+		f.Unlock()
+		if ok {
+			return f, test
+		}
+	}
+	return nil, nil
+}
+
 func (r *runTime) run() {
 	defer func() {
 		close(r.buildDone)
 		close(r.testDone)
 	}()
+	jobs := make([]*schedJob, 0, len(r.runFlow))
 	for _, f := range r.runFlow {
-		r.flowWg.Add(1)
-		runTimeLimiter.put()
-		go func(flow *runFlow) {
-			defer r.flowWg.Add(-1)
-			flow.run()
-		}(f)
-	}
-	r.flowWg.Wait()
+		jobs = append(jobs, &schedJob{name: f.build.Name, group: f.build.Name, run: f.run})
+	}
+	r.sched.submitBuild(jobs...)
+	r.sched.wait()
 	r.cancel()
 }
 
@@ -394,14 +627,28 @@ func (r *runTime) signalHandler(sc chan os.Signal) {
 		signal.Notify(sc, os.Interrupt, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 		select {
 		case s := <-sc:
-			Println("receive signal" + s.String())
-			r.cancel()
+			r.stop("receive signal" + s.String())
 		case <-r.ctx.Done():
 			return
 		}
 	}
 }
 
+func (r *runTime) collectBuildResult(result *errors.JVSRuntimeResult) {
+	for _, reporter := range r.reporters {
+		reporter.CollectBuildResult(result)
+	}
+	r.checkBuildBudget(result)
+}
+
+func (r *runTime) collectTestResult(result *errors.JVSRuntimeResult) {
+	result = r.waive(result)
+	for _, reporter := range r.reporters {
+		reporter.CollectTestResult(result)
+	}
+	r.checkTestBudget(result)
+}
+
 func (r *runTime) monitor() {
 LableFor:
 	for {
@@ -409,18 +656,14 @@ LableFor:
 		case result, ok := <-r.buildDone:
 			{
 				if ok {
-					for _, reporter := range r.reporters {
-						reporter.CollectBuildResult(result)
-					}
+					r.collectBuildResult(result)
 				}
 				break
 			}
 		case result, ok := <-r.testDone:
 			{
 				if ok {
-					for _, reporter := range r.reporters {
-						reporter.CollectTestResult(result)
-					}
+					r.collectTestResult(result)
 				}
 				break
 			}
@@ -428,15 +671,35 @@ LableFor:
 			break LableFor
 		}
 	}
+	//channels have been closed, drain results left
+	for result := range r.buildDone {
+		r.collectBuildResult(result)
+	}
+	for result := range r.testDone {
+		r.collectTestResult(result)
+	}
 	for _, reporter := range r.reporters {
 		reporter.Report()
 	}
+	r.printWaivers()
 }
 
 func (r *runTime) daemon(sc chan os.Signal) {
 
 	defer r.exit()
-	r.addReporter(status)
+	Println(utils.Brown("jobId " + r.runtimeId + " master_seed " + strconv.Itoa(r.masterSeed) + ", use -master_seed " + strconv.Itoa(r.masterSeed) + " to regenerate the same seeds"))
+	if runTimeShard.enabled() {
+		Println(utils.Brown("jobId " + r.runtimeId + " runs shard " + runTimeShard.String()))
+	}
+	status.masterSeed = r.masterSeed
+	status.shard = runTimeShard.String()
+	r.addReporter(status, newJobRecorder(r))
+	if r.resume != nil {
+		r.resume.feed(r)
+	}
+	for _, result := range r.filtered {
+		r.collectTestResult(result)
+	}
 
 	// run
 
@@ -462,7 +725,7 @@ func filterAstArgs(args []string) []interface{} {
 	return _args
 }
 
-func run(name string, cfg map[interface{}]interface{}, sc chan os.Signal) error {
+func run(name string, args []string, cfg map[interface{}]interface{}, sc chan os.Signal) error {
 	group := loader.NewAstGroup("Jarvis")
 	if err := group.Parse(cfg); err != nil {
 		return err
@@ -470,8 +733,17 @@ func run(name string, cfg map[interface{}]interface{}, sc chan os.Signal) error
 	if err := group.Link(); err != nil {
 		return err
 	}
-	r := newRunTime(name, group)
-	logFile, err := setLog(r.runtimeId + ".log")
+	if err := checkOptions(); err != nil {
+		runTimeFinish()
+		return err
+	}
+	resume, err := loadResume(runTimeResume)
+	if err != nil {
+		return err
+	}
+	r := newRunTime(name, group, resume)
+	r.args = args
+	logFile, err := setLog(r.logId() + ".log")
 	defer func() {
 		Println("logFile:" + logFile.Name())
 		logFile.Close()
@@ -479,21 +751,48 @@ func run(name string, cfg map[interface{}]interface{}, sc chan os.Signal) error
 	if err != nil {
 		return err
 	}
+	r.logFile = logFile.Name()
 	r.daemon(sc)
 	return nil
 }
 
 func RunGroup(groupName string, args []string, sc chan os.Signal) error {
-	return run(groupName, map[interface{}]interface{}{"args": filterAstArgs(args), "groups": []interface{}{groupName}}, sc)
+	return run(groupName, args, map[interface{}]interface{}{"args": filterAstArgs(args), "groups": []interface{}{groupName}}, sc)
+}
+
+//groups not included by other groups, so that tests in subgroups are not run repeatedly
+func topGroups() []interface{} {
+	root := loader.GetJvsAstRoot()
+	subGroups := make(map[string]bool)
+	for _, name := range root.GetAllGroups() {
+		for sub := range root.GetGroup(name).Groups {
+			subGroups[sub] = true
+		}
+	}
+	groups := make([]interface{}, 0)
+	names := root.GetAllGroups()
+	sort.Strings(names)
+	for _, name := range names {
+		if !subGroups[name] {
+			groups = append(groups, name)
+		}
+	}
+	return groups
+}
+
+//run tests of all groups whose tags match the expression
+func RunTags(tagExpr string, args []string, sc chan os.Signal) error {
+	args = append(args, "-tags "+tagExpr)
+	return run(tagExpr, args, map[interface{}]interface{}{"args": filterAstArgs(args), "groups": topGroups()}, sc)
 }
 
 func RunTest(testName, buildName string, args []string, sc chan os.Signal) error {
-	return run(testName, map[interface{}]interface{}{"build": buildName,
+	return run(testName, args, map[interface{}]interface{}{"build": buildName,
 		"args":  filterAstArgs(args),
 		"tests": []interface{}{map[interface{}]interface{}{testName: nil}}}, sc)
 }
 
 func RunOnlyBuild(buildName string, args []string, sc chan os.Signal) error {
-	return run(buildName, map[interface{}]interface{}{"build": buildName,
+	return run(buildName, args, map[interface{}]interface{}{"build": buildName,
 		"args": filterAstArgs(args)}, sc)
 }
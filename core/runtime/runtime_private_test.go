@@ -1,10 +1,19 @@
 package runtime
 
 import (
+	"github.com/shady831213/jarvism/core"
+	"github.com/shady831213/jarvism/core/errors"
+	"github.com/shady831213/jarvism/core/jobs"
 	"github.com/shady831213/jarvism/core/loader"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func setUp(name string, cfg map[interface{}]interface{}) (*runTime, error) {
@@ -15,7 +24,7 @@ func setUp(name string, cfg map[interface{}]interface{}) (*runTime, error) {
 	if err := group.Link(); err != nil {
 		return nil, err
 	}
-	return newRunTime(name, group), nil
+	return newRunTime(name, group, nil), nil
 }
 
 func setUpGroup(group *loader.AstGroup, args []string) (*runTime, error) {
@@ -111,37 +120,541 @@ func TestRunOnlyBuildSetup(t *testing.T) {
 
 }
 
+//hash of build1 of test1 with args
+func buildHashOf(t *testing.T, args ...string) string {
+	defer runTimeFinish()
+	r, err := setUpTest("test1", "build1", append([]string{"-seed 1"}, args...))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for k := range r.runFlow {
+		return k
+	}
+	return ""
+}
+
 func TestUnique(t *testing.T) {
+	hash := buildHashOf(t, "-unique")
+	time.Sleep(time.Millisecond)
+	if newHash := buildHashOf(t, "-unique"); newHash == hash {
+		t.Error("expect a new hash of -unique build in each job but get", hash)
+		t.FailNow()
+	}
+	if buildHashOf(t) == hash {
+		t.Error("expect a different hash without -unique but get", hash)
+		t.FailNow()
+	}
+}
+
+func TestNotUnique(t *testing.T) {
+	hash := buildHashOf(t)
+	if newHash := buildHashOf(t); newHash != hash {
+		t.Error("expect the same hash of unchanged build but get", hash, newHash)
+		t.FailNow()
+	}
+	if newHash := buildHashOf(t, "-test_phase a"); newHash == hash {
+		t.Error("expect a new hash after compile option changed!")
+		t.FailNow()
+	}
+	if buildHashOf(t, "-test_phase a") == buildHashOf(t, "-test_phase b") {
+		t.Error("expect a new hash after option value changed!")
+		t.FailNow()
+	}
+}
+
+func TestBuildSignEnv(t *testing.T) {
+	defer os.Unsetenv("JVS_SIGN_TEST")
+	os.Setenv("JVS_SIGN_TEST", "a")
+	hash := buildHashOf(t, "-test_phase $JVS_SIGN_TEST")
+	os.Setenv("JVS_SIGN_TEST", "b")
+	if newHash := buildHashOf(t, "-test_phase $JVS_SIGN_TEST"); newHash == hash {
+		t.Error("expect a new hash after $JVS_SIGN_TEST changed!")
+		t.FailNow()
+	}
+}
+
+func TestBuildSignOptionFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jvs_sign")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	src := path.Join(dir, "top.sv")
+	list := path.Join(dir, "top.f")
+	if err := ioutil.WriteFile(src, []byte("module top;endmodule\n"), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := ioutil.WriteFile(list, []byte("//sources\n+incdir+"+dir+"\ntop.sv\n"), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	hash := buildHashOf(t, "-test_phase -F "+list)
+	if err := ioutil.WriteFile(src, []byte("module top;\nendmodule\n"), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if newHash := buildHashOf(t, "-test_phase -F "+list); newHash == hash {
+		t.Error("expect a new hash after " + src + " in " + list + " changed!")
+		t.FailNow()
+	}
+}
+
+func TestBuildCancelled(t *testing.T) {
 	defer runTimeFinish()
-	r, err := setUpTest("test1", "build1", []string{"-seed 1", "-unique"})
+	r, err := setUpTest("test1", "build1", []string{"-repeat 2"})
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
+	for _, f := range r.runFlow {
+		f.buildNotPassed(errors.JVSRuntimeResultFail("build1 failed"))
+		r.cancel()
+		f.buildNotPassed(errors.JVSRuntimeResultUnknown("build1 is killed"))
+	}
+	for i, reason := range []string{errors.JVSRuntimeSkipBuildFailed, errors.JVSRuntimeSkipBuildFailed, errors.JVSRuntimeSkipCancelled, errors.JVSRuntimeSkipCancelled} {
+		result := <-r.testDone
+		if result.Status != errors.JVSRuntimeSkipped || result.GetMsgs()[0] != reason {
+			t.Error("expect test " + strconv.Itoa(i) + " skipped for " + reason + " but get " + result.Error())
+			t.FailNow()
+		}
+	}
+}
 
-	for k, v := range r.runFlow {
-		expHash := hashFunc(strings.Replace(r.runtimeId+"build1"+v.build.PreCompileAction()+v.build.CompileOption()+v.build.PostCompileAction(), " ", "", -1))
-		if k != expHash {
-			t.Error("expect", expHash, "but get", k)
+func TestTimeoutSetup(t *testing.T) {
+	defer runTimeFinish()
+	r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group2"), []string{"-timeout 5m"})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if runTimeTimeout != 5*time.Minute {
+		t.Error("runTimeTimeout expect 5m, but get " + runTimeTimeout.String())
+		t.FailNow()
+	}
+	for _, f := range r.runFlow {
+		if strings.Contains(f.build.Name, "__build2_") && f.build.GetTimeout() != time.Hour {
+			t.Error("build timeout expect 1h, but get " + f.build.GetTimeout().String())
 			t.FailNow()
 		}
+		for _, test := range f.testCases {
+			if test.GetTimeout() != 600*time.Second {
+				t.Error("test timeout of " + test.Name + " expect 10m, but get " + test.GetTimeout().String())
+				t.FailNow()
+			}
+		}
 	}
+}
 
+func TestSchedulerSetup(t *testing.T) {
+	defer runTimeFinish()
+	r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group2"), []string{"-max_job 3", "-max_test_job 5", "-sched_policy round_robin"})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if r.sched.build.limit != 3 || r.sched.test.limit != 5 {
+		t.Error("expect build limit 3 and test limit 5, but get " + strconv.Itoa(r.sched.build.limit) + " and " + strconv.Itoa(r.sched.test.limit))
+		t.FailNow()
+	}
+	if r.sched.policy != schedPolicyRoundRobin {
+		t.Error("sched policy expect " + schedPolicyRoundRobin + ", but get " + r.sched.policy)
+		t.FailNow()
+	}
 }
 
-func TestNotUnique(t *testing.T) {
+func TestSchedulerPolicy(t *testing.T) {
 	defer runTimeFinish()
-	r, err := setUpTest("test1", "build1", []string{"-seed 1"})
+	for policy, exp := range map[string][]string{schedPolicyName: {"a1", "a2", "a3", "b1", "b2"},
+		schedPolicyRoundRobin: {"b1", "a1", "b2", "a2", "a3"}} {
+		runTimeSchedPolicy = schedPolicyVar{policy}
+		runTimeMaxTestJob = 1
+		s := newScheduler(nil)
+		order := make([]string, 0)
+		block := make(chan bool)
+		job := func(name, group string) *schedJob {
+			return &schedJob{name: name, group: group, run: func() {
+				<-block
+				order = append(order, name)
+			}}
+		}
+		//all jobs are queued before the first one is done
+		s.submitTest(job("a0", "a"))
+		s.submitTest(job("b2", "b"), job("a3", "a"), job("b1", "b"), job("a1", "a"), job("a2", "a"))
+		close(block)
+		s.wait()
+		if strings.Join(order[1:], " ") != strings.Join(exp, " ") {
+			t.Error(policy + " expect order " + strings.Join(exp, " ") + ", but get " + strings.Join(order[1:], " "))
+			t.FailNow()
+		}
+	}
+}
+
+func TestRetrySetup(t *testing.T) {
+	defer runTimeFinish()
+	r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group2"), []string{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	timeout := errors.JVSRuntimeResultTimeout("killed at 2019-01-01 00:00:00!")
+	for _, f := range r.runFlow {
+		for _, test := range f.testCases {
+			retry := test.GetRetry()
+			if retry == nil || retry.Times != 1 || !retry.NewSeed {
+				t.Error("expect " + test.Name + " retry once with new seed!")
+				t.FailNow()
+			}
+			if !retry.ShouldRetry(timeout, 0) || retry.ShouldRetry(timeout, 1) || retry.ShouldRetry(errors.JVSRuntimeResultFail("killed"), 0) {
+				t.Error("expect " + test.Name + " only retry timeout once!")
+				t.FailNow()
+			}
+			reseeded := test.Reseed(0)
+			f.bindTest(reseeded)
+			if reseeded.Name == test.Name || reseeded.GetBuild() != f.build {
+				t.Error("expect " + test.Name + " reseeded with the same build!")
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestSeedsSetup(t *testing.T) {
+	for _, c := range []struct {
+		args  []string
+		seeds []string
+	}{{[]string{}, []string{"test1__1", "test1__2", "test1__3", "test2__4"}},
+		{[]string{"-seeds_file $JVS_PRJ_HOME/seeds.txt"}, []string{"test1__5", "test1__6", "test1__7", "test2__5", "test2__6", "test2__7"}},
+		{[]string{"-seed 9"}, []string{"test1__9", "test2__9"}}} {
+		r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group4"), c.args)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		names := make([]string, 0)
+		for _, f := range r.runFlow {
+			for _, test := range f.testCases {
+				names = append(names, test.Identity.Test+"__"+strconv.Itoa(test.Identity.Seed))
+			}
+		}
+		sort.Strings(names)
+		if strings.Join(names, " ") != strings.Join(c.seeds, " ") {
+			t.Error("expect " + strings.Join(c.seeds, " ") + " but get " + strings.Join(names, " "))
+			t.FailNow()
+		}
+		runTimeFinish()
+	}
+}
+
+func TestShardSetup(t *testing.T) {
+	keys := func(r *runTime) []string {
+		keys := make([]string, 0)
+		for _, f := range r.runFlow {
+			if len(f.testCases) == 0 {
+				t.Error("expect build " + f.build.Name + " is dropped!")
+				t.FailNow()
+			}
+			for _, test := range f.testCases {
+				keys = append(keys, test.Identity.Key())
+			}
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group3"), []string{"-master_seed 3"})
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
+	all := keys(r)
+	runTimeFinish()
+	sharded := make([]string, 0)
+	for i := 1; i <= 3; i++ {
+		r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group3"), []string{"-master_seed 3", "-shard " + strconv.Itoa(i) + "/3"})
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if r.totalTest != (len(all)+3-i)/3 {
+			t.Error("expect " + strconv.Itoa((len(all)+3-i)/3) + " tests in shard " + strconv.Itoa(i) + " but get " + strconv.Itoa(r.totalTest))
+			t.FailNow()
+		}
+		sharded = append(sharded, keys(r)...)
+		runTimeFinish()
+	}
+	sort.Strings(sharded)
+	if strings.Join(all, " ") != strings.Join(sharded, " ") {
+		t.Error("expect shards cover all tests exactly once!")
+		t.FailNow()
+	}
+}
 
-	for k, v := range r.runFlow {
-		expHash := hashFunc(strings.Replace("build1"+v.build.PreCompileAction()+v.build.CompileOption()+v.build.PostCompileAction(), " ", "", -1))
-		if k != expHash {
-			t.Error("expect", expHash, "but get", k)
+func TestIdentitySetup(t *testing.T) {
+	defer runTimeFinish()
+	r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group3"), []string{"-master_seed 3"})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for hash, f := range r.runFlow {
+		build := f.build.Identity
+		if build.JobId != r.runtimeId || build.BuildHash != hash || build.IsTest() || f.build.Name != build.String() {
+			t.Error("unexpected identity of build "+f.build.Name, build)
 			t.FailNow()
 		}
+		for name, test := range f.testCases {
+			id := test.Identity
+			if id.JobId != build.JobId || id.Build != build.Build || id.BuildHash != hash || !id.IsTest() || name != id.Key() || test.Name != id.String() ||
+				len(id.Groups) < 2 || id.Groups[1] != "group3" {
+				t.Error("unexpected identity of test "+name, id)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestFilterSetup(t *testing.T) {
+	defer runTimeFinish()
+	r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group3"), []string{"-include __test1$", "-exclude __group1__"})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if r.totalTest == 0 {
+		t.Error("expect test1 of group3 is included!")
+		t.FailNow()
+	}
+	for _, f := range r.runFlow {
+		for name, test := range f.testCases {
+			if test.Identity.Test != "test1" || strings.Contains(strings.Join(test.Identity.Groups, " "), "group1") {
+				t.Error("expect " + name + " is filtered!")
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestFilterSkipped(t *testing.T) {
+	defer runTimeFinish()
+	r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group3"), []string{"-include __test1$"})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(r.filtered) == 0 {
+		t.Error("expect filtered tests are reported!")
+		t.FailNow()
+	}
+	for _, result := range r.filtered {
+		if result.Status != errors.JVSRuntimeSkipped || result.Identity.Test == "test1" || !strings.Contains(result.Error(), errors.JVSRuntimeSkipFiltered) {
+			t.Error("unexpected filtered result " + result.Error())
+			t.FailNow()
+		}
+	}
+	selected := 0
+	for _, f := range r.runFlow {
+		selected += len(f.testCases)
+	}
+	if r.totalTest != selected+len(r.filtered) {
+		t.Error("expect filtered tests are counted in total!")
+		t.FailNow()
+	}
+}
+
+func TestTagsSetup(t *testing.T) {
+	defer runTimeFinish()
+	r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group4"), []string{"-tags smoke && !long"})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if r.totalTest-len(r.filtered) != 3 {
+		t.Error("expect 3 tests but get " + strconv.Itoa(r.totalTest-len(r.filtered)) + "!")
+		t.FailNow()
+	}
+	if len(r.filtered) != 1 {
+		t.Error("expect 1 filtered test but get " + strconv.Itoa(len(r.filtered)) + "!")
+		t.FailNow()
+	}
+	for _, f := range r.runFlow {
+		for name, test := range f.testCases {
+			if test.Identity.Test != "test1" {
+				t.Error("expect " + name + " is filtered!")
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestTagExpr(t *testing.T) {
+	tags := []string{"smoke", "ddr"}
+	for s, expect := range map[string]bool{
+		"smoke":                   true,
+		"!smoke":                  false,
+		"smoke && !long":          true,
+		"long || ddr && smoke":    true,
+		"(long || ddr) && !smoke": false,
+		"!(long || pcie)":         true,
+	} {
+		expr, err := loader.ParseTagExpr(s)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if expr.Match(tags) != expect {
+			t.Error("expect " + s + " is " + strconv.FormatBool(expect) + "!")
+		}
+	}
+	for _, s := range []string{"", "smoke &&", "(smoke", "smoke long", "smoke & ddr"} {
+		if _, err := loader.ParseTagExpr(s); err == nil {
+			t.Error("expect " + s + " is invalid!")
+		}
+	}
+}
+
+func TestLoadTestList(t *testing.T) {
+	expect := []*testEntry{{"build1", "test1", 1, true, []string{"-vh"}},
+		{"build2", "test3", 0, false, []string{"-test_phase main"}},
+		{"build1", "test2", 2, true, []string{}}}
+	for _, file := range []string{"list.txt", "list.csv", "list.yaml"} {
+		entries, err := loadTestList("$JVS_PRJ_HOME/" + file)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if !reflect.DeepEqual(entries, expect) {
+			t.Error("unexpected tests in " + file + "!")
+			for _, e := range entries {
+				t.Log(*e)
+			}
+		}
+	}
+}
+
+func TestRetryNewSeed(t *testing.T) {
+	defer os.RemoveAll(core.GetJobsDir())
+	args := []string{"-sim_only", "-timeout 1ns", "-seed 1"}
+	cfg := map[interface{}]interface{}{"build": "build1", "args": filterAstArgs(args),
+		"retry": map[interface{}]interface{}{"times": 1, "new_seed": true},
+		"tests": []interface{}{map[interface{}]interface{}{"test1": nil}}}
+	if err := run("test1", args, cfg, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	job := records[0]
+	if len(job.Tests) != 1 || len(job.Tests[0].Attempts) != 1 || len(job.Plan) != 1 {
+		t.Error("expect 1 test retried once!")
+		t.FailNow()
+	}
+	test := job.Tests[0]
+	if test.Attempts[0].Seed != 1 || test.Seed == 1 || job.Plan[0].Seed != 1 {
+		t.Error("expect the first attempt with seed 1 and the final result with a new seed but get " + strconv.Itoa(test.Attempts[0].Seed) + " and " + strconv.Itoa(test.Seed) + "!")
+		t.FailNow()
+	}
+	if test.PlanKey() != job.Plan[0].Key() || len(test.Args) == 0 {
+		t.Error("expect the final result is recorded as the planned test!")
+		t.FailNow()
+	}
+}
+
+func TestTestEntryArgs(t *testing.T) {
+	if args := (&testEntry{"build1", "test1", 0, true, []string{"-vh"}}).testArgs(); !reflect.DeepEqual(args, []interface{}{"-vh", "-seed 0"}) {
+		t.Error("expect seed 0 is kept but get", args)
+	}
+	if args := (&testEntry{"build1", "test1", 0, false, []string{}}).testArgs(); len(args) != 0 {
+		t.Error("expect no seed but get", args)
+	}
+}
+
+func TestMatrixSetup(t *testing.T) {
+	defer runTimeFinish()
+	r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group5"), []string{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(r.runFlow) != 2 {
+		t.Error("expect 2 runFlow but get " + strconv.Itoa(len(r.runFlow)))
+		t.FailNow()
+	}
+	tests := make([]string, 0)
+	for _, f := range r.runFlow {
+		for _, test := range f.testCases {
+			tests = append(tests, strings.Join(append(test.Identity.Groups[2:], test.Identity.Test), "__"))
+		}
+	}
+	sort.Strings(tests)
+	expect := []string{"test_phase=p1__test2",
+		"test_phase=p1__vh=false__test1",
+		"test_phase=p1__vh=true__test1",
+		"test_phase=p2__test2",
+		"test_phase=p2__vh=false__test1",
+		"test_phase=p2__vh=true__test1"}
+	if strings.Join(tests, " ") != strings.Join(expect, " ") {
+		t.Error("expect tests", expect, "but get", tests)
+	}
+}
+
+func TestBuildsSetup(t *testing.T) {
+	defer runTimeFinish()
+	r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group6"), []string{})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(r.runFlow) != 2 || r.totalTest != 3 {
+		t.Error("expect 2 runFlow and 3 tests but get " + strconv.Itoa(len(r.runFlow)) + " runFlow and " + strconv.Itoa(r.totalTest) + " tests")
+		t.FailNow()
+	}
+	tests := make([]string, 0)
+	for _, f := range r.runFlow {
+		for _, test := range f.testCases {
+			tests = append(tests, test.Identity.Build+"__"+test.Identity.Test)
+		}
+	}
+	sort.Strings(tests)
+	expect := []string{"build1__test1", "build1__test2", "build2__test1"}
+	if strings.Join(tests, " ") != strings.Join(expect, " ") {
+		t.Error("expect tests", expect, "but get", tests)
+	}
+}
+
+func TestSampleSetup(t *testing.T) {
+	sampled := func() map[string]int {
+		defer runTimeFinish()
+		r, err := setUpGroup(loader.GetJvsAstRoot().GetGroup("group1"), []string{"-sample 20", "-master_seed 3"})
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if r.totalTest != 20 {
+			t.Error("expect 20 tests but get " + strconv.Itoa(r.totalTest))
+			t.FailNow()
+		}
+		tests := make(map[string]int)
+		for _, f := range r.runFlow {
+			for _, test := range f.testCases {
+				tests[test.Identity.Test+"__"+strconv.Itoa(test.Identity.Seed)]++
+			}
+		}
+		return tests
+	}
+	tests := sampled()
+	cnts := make(map[string]int)
+	for name := range tests {
+		cnts[strings.Split(name, "__")[0]]++
+	}
+	if cnts["test2"] <= cnts["test1"] {
+		t.Error("expect test2 with weight 3 is picked more, but get", cnts)
+	}
+	if !reflect.DeepEqual(tests, sampled()) {
+		t.Error("expect the same master seed picks the same tests!")
 	}
 }
@@ -2,6 +2,8 @@ package runtime_test
 
 import (
 	"github.com/shady831213/jarvism/core"
+	"github.com/shady831213/jarvism/core/errors"
+	"github.com/shady831213/jarvism/core/jobs"
 	"github.com/shady831213/jarvism/core/loader"
 	"github.com/shady831213/jarvism/core/runtime"
 	"math/rand"
@@ -10,6 +12,7 @@ import (
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -21,6 +24,7 @@ func setup() {
 
 func tearDonw() {
 	os.RemoveAll(path.Join(core.GetWorkDir(), "JarvismLog"))
+	os.RemoveAll(core.GetJobsDir())
 	os.RemoveAll("/tmp/jarvism_plugins")
 }
 
@@ -72,6 +76,552 @@ func TestRunOnlyBuild(t *testing.T) {
 
 }
 
+func TestTimeout(t *testing.T) {
+	setup()
+	if err := runtime.RunTest("test1", "build1", []string{"-timeout 1ns"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if runtime.GetBuildStatus().Cnts[errors.JVSRuntimeTimeout] != 1 {
+		t.Error("expect build timeout but it is not!")
+		t.FailNow()
+	}
+	if runtime.GetTestStatus().Cnts[errors.JVSRuntimeSkipped] != 1 {
+		t.Error("expect test of timeout build skipped but it is not!")
+		t.FailNow()
+	}
+	tearDonw()
+}
+
+func TestFiltered(t *testing.T) {
+	setup()
+	if err := runtime.RunGroup("group4", []string{"-sim_only", "-tags smoke && !long"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	filtered := 0
+	for _, test := range records[0].Tests {
+		if test.GetStatus() == errors.JVSRuntimeSkipped && len(test.Msgs) > 0 && test.Msgs[0] == errors.JVSRuntimeSkipFiltered {
+			filtered++
+		}
+	}
+	if filtered != 1 || runtime.GetTestStatus().Cnts[errors.JVSRuntimeSkipped] != 1 {
+		t.Error("expect 1 test skipped by filter but get " + strconv.Itoa(filtered) + "!")
+		t.FailNow()
+	}
+	tearDonw()
+}
+
+func TestSignatures(t *testing.T) {
+	setup()
+	if err := runtime.RunTest("test1", "build1", []string{"-sim_only", "-timeout 1ns", "-repeat 5"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	signatures := records[0].Signatures
+	if len(signatures) != 1 || signatures[0].Count != 5 || signatures[0].Status != "TIMEOUT" {
+		t.Error("expect 5 timeout tests share one signature!")
+		for _, s := range signatures {
+			t.Log(*s)
+		}
+		t.FailNow()
+	}
+	tearDonw()
+}
+
+func TestWaivers(t *testing.T) {
+	setup()
+	if err := runtime.RunTest("test3", "build1", []string{"-sim_only", "-timeout 1ns", "-repeat 5"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if runtime.GetTestStatus().Cnts[errors.JVSRuntimeWaived] != 5 || runtime.Failed() {
+		t.Error("expect 5 timeout tests waived!")
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(records[0].Signatures) != 0 {
+		t.Error("expect waived tests have no failure signature!")
+		t.FailNow()
+	}
+	//waiver of test2 has expired
+	if err := runtime.RunTest("test2", "build1", []string{"-sim_only", "-timeout 1ns"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if runtime.GetTestStatus().Cnts[errors.JVSRuntimeWaived] != 0 || !runtime.Failed() {
+		t.Error("expect expired waiver doesn't work!")
+		t.FailNow()
+	}
+	tearDonw()
+}
+
+func TestResumeWaivers(t *testing.T) {
+	setup()
+	if err := runtime.RunTest("test3", "build1", []string{"-sim_only", "-timeout 1ns", "-repeat 2"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	//a timeout finished before the waiver was added, and one test never finished
+	job := records[0]
+	job.Tests[0].Status = errors.StatusString(errors.JVSRuntimeTimeout)
+	job.Tests[0].Msgs = job.Tests[0].Msgs[1:]
+	job.Tests = job.Tests[:1]
+	job.Interrupted = true
+	if _, err := jobs.Save(job); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := runtime.RunTest("test3", "build1", []string{"-sim_only", "-timeout 1ns", "-repeat 2", "-resume " + job.JobId}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if runtime.GetTestStatus().Cnts[errors.JVSRuntimeWaived] != 2 || runtime.Failed() {
+		t.Error("expect reused timeout test is waived!")
+		t.FailNow()
+	}
+	resumed, err := jobs.Load(job.JobId)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for _, test := range resumed.Tests {
+		if test.GetStatus() != errors.JVSRuntimeWaived {
+			t.Error("expect waived test in resumed job but get " + test.Status)
+			t.FailNow()
+		}
+	}
+	tearDonw()
+}
+
+func TestMaxFail(t *testing.T) {
+	setup()
+	for _, arg := range []string{"-max_fail 0", "-max_fail_rate 5"} {
+		if err := runtime.RunTest("test1", "build1", []string{"-sim_only", "-timeout 1ns", "-repeat 20", "-max_job 1", arg}, nil); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		cnts := runtime.GetTestStatus().Cnts
+		if cnts[errors.JVSRuntimeSkipped] == 0 || cnts[errors.JVSRuntimeTimeout]+cnts[errors.JVSRuntimeUnknown]+cnts[errors.JVSRuntimeSkipped] != 20 {
+			t.Error("expect skipped tests after " + arg + " exceeded!")
+			t.FailNow()
+		}
+	}
+	tearDonw()
+}
+
+func TestResumeMaxFail(t *testing.T) {
+	setup()
+	if err := runtime.RunTest("test2", "build1", []string{"-sim_only", "-timeout 1ns", "-repeat 3"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	//2 failed tests finished and one never finished
+	job := records[0]
+	job.Tests = job.Tests[:2]
+	job.Interrupted = true
+	if _, err := jobs.Save(job); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := runtime.RunTest("test2", "build1", []string{"-sim_only", "-timeout 1ns", "-repeat 3", "-max_fail 1", "-resume " + job.JobId}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if runtime.GetTestStatus().Cnts[errors.JVSRuntimeSkipped] != 1 {
+		t.Error("expect failed tests reused count for -max_fail!")
+		t.FailNow()
+	}
+	resumed, err := jobs.Load(job.JobId)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if !resumed.Interrupted || !strings.Contains(resumed.StopReason, "max_fail") {
+		t.Error("expect job is stopped by max_fail but get " + resumed.StopReason)
+		t.FailNow()
+	}
+	tearDonw()
+}
+
+func TestRetry(t *testing.T) {
+	setup()
+	if err := runtime.RunTest("test1", "build1", []string{"-sim_only", "-timeout 1ns", "-retry 2"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	test := records[0].Tests[0]
+	if test.GetStatus() == errors.JVSRuntimePass || len(test.Attempts) != 2 {
+		t.Error("expect test failed after 2 retries!")
+		t.FailNow()
+	}
+	for _, attempt := range test.Attempts {
+		if attempt.Seed != test.Seed {
+			t.Error("expect retry with the same seed " + strconv.Itoa(test.Seed) + " but get " + strconv.Itoa(attempt.Seed))
+			t.FailNow()
+		}
+	}
+	tearDonw()
+}
+
+func TestDuration(t *testing.T) {
+	setup()
+	start := time.Now()
+	if err := runtime.RunGroup("group1", []string{"-sim_only", "-duration 1s", "-max_test_job 2"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if time.Since(start) < time.Second {
+		t.Error("expect job runs at least 1s!")
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	done := 0
+	for _, cnt := range runtime.GetTestStatus().Cnts {
+		done += cnt
+	}
+	if len(records[0].Tests) == 0 || done != len(records[0].Tests) {
+		t.Error("expect all launched tests are reported!")
+		t.FailNow()
+	}
+	names := make(map[string]bool)
+	for _, test := range records[0].Tests {
+		if names[test.Name] {
+			t.Error("expect new seed each time but " + test.Name + " is launched repeatedly!")
+		}
+		names[test.Name] = true
+	}
+	tearDonw()
+}
+
+func TestDurationConflicts(t *testing.T) {
+	setup()
+	for _, arg := range []string{"-shard 1/2", "-resume 20190101_0000000000"} {
+		if err := runtime.RunTest("test1", "build1", []string{"-sim_only", "-master_seed 1", "-duration 1s", arg}, nil); err == nil {
+			t.Error("expect -duration can't work with " + arg + "!")
+			t.FailNow()
+		}
+	}
+	//options are reset after rejected
+	if err := runtime.RunTest("test1", "build1", []string{"-sim_only", "-seed 1"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	tearDonw()
+}
+
+func TestShardMasterSeed(t *testing.T) {
+	setup()
+	if err := runtime.RunGroup("group1", []string{"-sim_only", "-shard 1/2"}, nil); err == nil || !strings.Contains(err.Error(), "-master_seed") {
+		t.Error("expect -shard can't work without -master_seed!")
+		t.FailNow()
+	}
+	if err := runtime.RunGroup("group1", []string{"-sim_only", "-master_seed 1", "-shard 1/2"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	tearDonw()
+}
+
+func TestMasterSeed(t *testing.T) {
+	setup()
+	keys := make([]string, 0)
+	for i := 0; i < 2; i++ {
+		if err := runtime.RunGroup("group3", []string{"-sim_only", "-master_seed 7"}, nil); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		records, err := jobs.Latest(1)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if records[0].MasterSeed != 7 {
+			t.Error("expect master seed 7 but get " + strconv.Itoa(records[0].MasterSeed))
+			t.FailNow()
+		}
+		for j, test := range records[0].Plan {
+			if i == 0 {
+				keys = append(keys, test.Key())
+				continue
+			}
+			if keys[j] != test.Key() {
+				t.Error("expect " + keys[j] + " regenerated but get " + test.Key())
+				t.FailNow()
+			}
+		}
+	}
+	tearDonw()
+}
+
+func TestJobRecord(t *testing.T) {
+	setup()
+	if err := runtime.RunTest("test1", "build1", []string{"-seed 1"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(records) != 1 || len(records[0].Builds) != 1 || len(records[0].Tests) != 1 {
+		t.Error("expect 1 job with 1 build and 1 test!")
+		t.FailNow()
+	}
+	test := records[0].Tests[0]
+	if test.Build != "build1" || test.Test != "test1" || test.Seed != 1 || test.BuildHash != records[0].Builds[0].BuildHash {
+		t.Error("unexpected test record", test)
+		t.FailNow()
+	}
+	if runtime.GetTestStatus().Cnts[test.GetStatus()] != 1 {
+		t.Error("unexpected test status " + test.Status)
+		t.FailNow()
+	}
+	tearDonw()
+}
+
+func TestPhases(t *testing.T) {
+	setup()
+	if err := runtime.RunTest("test1", "build1", []string{"-seed 1"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	for _, c := range []struct {
+		record *jobs.ResultRecord
+		phases []string
+	}{{records[0].Builds[0], []string{"prepare", "build", "check"}}, {records[0].Tests[0], []string{"prepare", "run", "check"}}} {
+		if len(c.record.Phases) != len(c.phases) {
+			t.Error("expect phases", c.phases, "of", c.record.Name, "but get", len(c.record.Phases))
+			t.FailNow()
+		}
+		for i, phase := range c.record.Phases {
+			if phase.Name != c.phases[i] || phase.EndTime.Before(phase.StartTime) || phase.StartTime.Before(c.record.StartTime) || phase.EndTime.After(c.record.EndTime) {
+				t.Error("unexpected phase", phase, "of", c.record.Name)
+				t.FailNow()
+			}
+		}
+	}
+	if runtime.GetTestStatus().Time <= 0 || runtime.GetTestStatus().Phases["run"] <= 0 {
+		t.Error("expect durations of tests in status!")
+		t.FailNow()
+	}
+	tearDonw()
+}
+
+func TestRerun(t *testing.T) {
+	setup()
+	if err := runtime.RunTest("test1", "build1", []string{"-repeat 2"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	job := records[0]
+	if err := runtime.RerunJob(job.JobId, []errors.JVSRuntimeStatus{errors.JVSRuntimeTimeout}, nil, nil); err == nil {
+		t.Error("expect no test to rerun!")
+		t.FailNow()
+	}
+	statuses := []errors.JVSRuntimeStatus{errors.JVSRuntimePass, errors.JVSRuntimeWarning, errors.JVSRuntimeFail, errors.JVSRuntimeUnknown}
+	if err := runtime.RerunJob(job.JobId, statuses, nil, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err = jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if records[0].JobId == job.JobId || len(records[0].Tests) != 2 {
+		t.Error("expect a new job with 2 tests!")
+		t.FailNow()
+	}
+	for i := range job.Tests {
+		if records[0].Tests[i].Seed != job.Tests[0].Seed && records[0].Tests[i].Seed != job.Tests[1].Seed {
+			t.Error("unexpected seed " + strconv.Itoa(records[0].Tests[i].Seed))
+		}
+	}
+	tearDonw()
+}
+
+func TestRerunSelected(t *testing.T) {
+	setup()
+	for _, run := range []func() error{
+		func() error { return runtime.RunGroup("group4", []string{"-sim_only", "-timeout 1ns", "-tags smoke"}, nil) },
+		func() error {
+			return runtime.RunGroup("group4", []string{"-sim_only", "-timeout 1ns", "-master_seed 1", "-shard 2/2"}, nil)
+		},
+		func() error {
+			return runtime.RunTest("test1", "build1", []string{"-sim_only", "-timeout 1ns", "-seeds_file $JVS_PRJ_HOME/seeds.txt"}, nil)
+		},
+	} {
+		if err := run(); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		records, err := jobs.Latest(1)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		job := records[0]
+		//rerun tests are in an ad-hoc group, so groups are not compared
+		runOf := func(test *jobs.ResultRecord) string {
+			return test.Build + " " + test.Test + " " + strconv.Itoa(test.Seed)
+		}
+		seeds := make(map[string]bool)
+		for _, test := range job.Tests {
+			if test.GetStatus() == errors.JVSRuntimeTimeout {
+				seeds[runOf(test)] = true
+			}
+		}
+		if err := runtime.RerunJob(job.JobId, []errors.JVSRuntimeStatus{errors.JVSRuntimeTimeout}, nil, nil); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if records, err = jobs.Latest(1); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if len(records[0].Tests) != len(seeds) {
+			t.Error("expect " + strconv.Itoa(len(seeds)) + " tests rerun from job with args " + strings.Join(job.Args, " ") + " but get " + strconv.Itoa(len(records[0].Tests)) + "!")
+			t.FailNow()
+		}
+		for _, test := range records[0].Tests {
+			if test.GetStatus() == errors.JVSRuntimeSkipped || !seeds[runOf(test)] {
+				t.Error("unexpected rerun test " + test.Path() + " " + test.Status + " of job with args " + strings.Join(job.Args, " "))
+				t.FailNow()
+			}
+		}
+	}
+	tearDonw()
+}
+
+func TestRunList(t *testing.T) {
+	setup()
+	if err := runtime.RunList("$JVS_PRJ_HOME/list.txt", []string{"-sim_only"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if records[0].Name != "list" || len(records[0].Tests) != 3 {
+		t.Error("expect job list with 3 tests!")
+		t.FailNow()
+	}
+	for _, test := range records[0].Tests {
+		if test.Test == "test1" && (test.Build != "build1" || test.Seed != 1) {
+			t.Error("unexpected test record", test.Key())
+		}
+	}
+	tearDonw()
+}
+
+func TestResume(t *testing.T) {
+	setup()
+	if err := runtime.RunTest("test1", "build1", []string{"-repeat 4"}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	records, err := jobs.Latest(1)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	job := records[0]
+	if len(job.Plan) != 4 || len(job.Tests) != 4 {
+		t.Error("expect 4 tests in plan and results!")
+		t.FailNow()
+	}
+	//one test is interrupted and one never finished
+	finished := job.Tests[0]
+	job.Tests[1].Interrupted = true
+	job.Tests = job.Tests[:3]
+	job.Interrupted = true
+	if _, err := jobs.Save(job); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	if err := runtime.RunTest("test1", "build1", []string{"-repeat 4", "-resume " + job.JobId}, nil); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if runtime.GetTestStatus().Cnts[errors.JVSRuntimePass]+runtime.GetTestStatus().Cnts[errors.JVSRuntimeWarning] != 4 {
+		t.Error("expect 4 tests in merged report!")
+		t.FailNow()
+	}
+	resumed, err := jobs.Load(job.JobId)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if resumed.Interrupted || len(resumed.Builds) != 1 || len(resumed.Tests) != 4 {
+		t.Error("expect 1 build and 4 tests in resumed job!")
+		t.FailNow()
+	}
+	if !resumed.Builds[0].StartTime.Equal(job.Builds[0].StartTime) {
+		t.Error("expect build is reused!")
+	}
+	names := make(map[string]bool)
+	for _, test := range resumed.Tests {
+		names[test.Name] = true
+		if test.Name == finished.Name && !test.StartTime.Equal(finished.StartTime) {
+			t.Error("expect " + test.Name + " is not run again!")
+		}
+	}
+	for _, test := range job.Plan {
+		if !names[test.Name] {
+			t.Error("expect " + test.Name + " in resumed job!")
+		}
+	}
+	tearDonw()
+}
+
 func TestInterrupt(t *testing.T) {
 	setup()
 	sc := make(chan os.Signal)
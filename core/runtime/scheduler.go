@@ -0,0 +1,177 @@
+package runtime
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//scheduler policies, both are deterministic for the same queue
+const (
+	//queued jobs are dispatched in name order
+	schedPolicyName = "name"
+	//queued tests take turns between builds, tests of the same build are dispatched in name order
+	schedPolicyRoundRobin = "round_robin"
+)
+
+var schedPolicies = []string{schedPolicyName, schedPolicyRoundRobin}
+
+type schedPolicyVar struct {
+	policy string
+}
+
+func (v *schedPolicyVar) Set(s string) error {
+	for _, p := range schedPolicies {
+		if s == p {
+			v.policy = s
+			return nil
+		}
+	}
+	return errors.New("unknown sched_policy " + s + ", valid policies are " + strings.Join(schedPolicies, ", ") + "!")
+}
+
+func (v *schedPolicyVar) String() string {
+	if v.policy == "" {
+		return schedPolicyName
+	}
+	return v.policy
+}
+
+func (v *schedPolicyVar) IsBoolFlag() bool {
+	return false
+}
+
+type schedJob struct {
+	name string
+	//build the job belongs to
+	group string
+	run   func()
+}
+
+//a pool limits running jobs of one kind, the rest wait in queue
+type schedPool struct {
+	limit   int
+	running int
+	queue   []*schedJob
+	//started jobs of each build, used by round_robin
+	started map[string]int
+}
+
+func newSchedPool(limit int) *schedPool {
+	inst := new(schedPool)
+	inst.limit = limit
+	inst.queue = make([]*schedJob, 0)
+	inst.started = make(map[string]int)
+	return inst
+}
+
+func (p *schedPool) full() bool {
+	return p.limit > 0 && p.running >= p.limit
+}
+
+func (p *schedPool) less(policy string, a, b *schedJob) bool {
+	if policy == schedPolicyRoundRobin && p.started[a.group] != p.started[b.group] {
+		return p.started[a.group] < p.started[b.group]
+	}
+	return a.name < b.name
+}
+
+func (p *schedPool) pop(policy string) *schedJob {
+	next := 0
+	for i := range p.queue {
+		if p.less(policy, p.queue[i], p.queue[next]) {
+			next = i
+		}
+	}
+	job := p.queue[next]
+	p.queue = append(p.queue[:next], p.queue[next+1:]...)
+	p.started[job.group]++
+	return job
+}
+
+func (p *schedPool) statusString() string {
+	return strconv.Itoa(len(p.queue)) + "/" + strconv.Itoa(p.running)
+}
+
+/*
+scheduler runs builds and tests in separate pools.
+
+Builds are limited by -max_build_job and tests are limited by -max_test_job, -max_job is the default of both.
+Jobs more than the limit wait in queue and are dispatched by -sched_policy.
+*/
+type scheduler struct {
+	sync.Mutex
+	policy   string
+	build    *schedPool
+	test     *schedPool
+	wg       sync.WaitGroup
+	onChange func(string)
+}
+
+func schedLimit(limit int) int {
+	if limit > 0 {
+		return limit
+	}
+	return runTimeMaxJob
+}
+
+func newScheduler(onChange func(string)) *scheduler {
+	inst := new(scheduler)
+	inst.policy = runTimeSchedPolicy.String()
+	inst.build = newSchedPool(schedLimit(runTimeMaxBuildJob))
+	inst.test = newSchedPool(schedLimit(runTimeMaxTestJob))
+	inst.wg = sync.WaitGroup{}
+	inst.onChange = onChange
+	return inst
+}
+
+func (s *scheduler) submit(p *schedPool, jobs ...*schedJob) {
+	s.Lock()
+	defer s.Unlock()
+	s.wg.Add(len(jobs))
+	p.queue = append(p.queue, jobs...)
+	s.dispatch(p)
+}
+
+func (s *scheduler) submitBuild(jobs ...*schedJob) {
+	s.submit(s.build, jobs...)
+}
+
+func (s *scheduler) submitTest(jobs ...*schedJob) {
+	s.submit(s.test, jobs...)
+}
+
+//must be called with lock held
+func (s *scheduler) dispatch(p *schedPool) {
+	for !p.full() && len(p.queue) > 0 {
+		job := p.pop(s.policy)
+		p.running++
+		go func() {
+			//jobs submitted by this job are counted before it is done
+			defer s.wg.Done()
+			defer s.finish(p)
+			job.run()
+		}()
+	}
+	if s.onChange != nil {
+		s.onChange(s.statusString())
+	}
+}
+
+func (s *scheduler) finish(p *schedPool) {
+	s.Lock()
+	defer s.Unlock()
+	p.running--
+	s.dispatch(p)
+}
+
+//wait until all submitted jobs are done
+func (s *scheduler) wait() {
+	s.wg.Wait()
+}
+
+//queued/running builds and tests
+func (s *scheduler) statusString() string {
+	return "[Q/R:(B:" + s.build.statusString() + "/T:" + s.test.statusString() + ")]"
+}
@@ -0,0 +1,85 @@
+package runtime
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//-shard i/n, i is in [1, n]
+type shardVar struct {
+	index, total int
+}
+
+func (v *shardVar) Set(s string) error {
+	fields := strings.Split(s, "/")
+	if len(fields) != 2 {
+		return errors.New("shard must be i/n but get " + s + "!")
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return errors.New("shard must be i/n but get " + s + "!")
+	}
+	total, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+	if err != nil {
+		return errors.New("shard must be i/n but get " + s + "!")
+	}
+	if total < 1 || index < 1 || index > total {
+		return errors.New("shard index must be in [1, " + strconv.Itoa(total) + "] but get " + s + "!")
+	}
+	v.index, v.total = index, total
+	return nil
+}
+
+func (v *shardVar) String() string {
+	if !v.enabled() {
+		return ""
+	}
+	return strconv.Itoa(v.index) + "/" + strconv.Itoa(v.total)
+}
+
+func (v *shardVar) IsBoolFlag() bool {
+	return false
+}
+
+func (v *shardVar) enabled() bool {
+	return v.total > 1
+}
+
+//keep tests of the shard only, tests are sorted by identity key and dealt to shards in turn,
+//so that all hosts get the same partition with the same config and master seed.
+//Builds no test of the shard needs are dropped.
+func (r *runTime) shard() {
+	if !runTimeShard.enabled() {
+		return
+	}
+	type shardTest struct {
+		key, name string
+		flow      *runFlow
+	}
+	tests := make([]shardTest, 0)
+	for _, f := range r.runFlow {
+		for name, test := range f.testCases {
+			tests = append(tests, shardTest{test.Identity.Key(), name, f})
+		}
+	}
+	//build only
+	if len(tests) == 0 {
+		return
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].key < tests[j].key
+	})
+	for i, test := range tests {
+		if i%runTimeShard.total != runTimeShard.index-1 {
+			delete(test.flow.testCases, test.name)
+			r.totalTest--
+		}
+	}
+	for hash, f := range r.runFlow {
+		if len(f.testCases) == 0 {
+			delete(r.runFlow, hash)
+		}
+	}
+}
@@ -5,30 +5,47 @@ import (
 	"github.com/shady831213/jarvism/core/errors"
 	"github.com/shady831213/jarvism/core/utils"
 	"strconv"
+	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 )
 
+//Time: sum of durations of results
+//
+//Phases: sum of durations of phases of results and their attempts, phaseKeys are in order of first seen
 type StatusCnt struct {
-	Cnts  map[errors.JVSRuntimeStatus]int
-	total int
-	keys  []errors.JVSRuntimeStatus
-	name  string
+	Cnts      map[errors.JVSRuntimeStatus]int
+	Time      time.Duration
+	Phases    map[string]time.Duration
+	total     int
+	keys      []errors.JVSRuntimeStatus
+	phaseKeys []string
+	name      string
 }
 
 func newStatusCnt(name string, total int) *StatusCnt {
 	inst := new(StatusCnt)
 	inst.name = name
 	inst.Cnts = make(map[errors.JVSRuntimeStatus]int)
+	inst.Phases = make(map[string]time.Duration)
 	inst.keys = make([]errors.JVSRuntimeStatus, 0)
+	inst.phaseKeys = make([]string, 0)
 	inst.total = total
 	inst.Cnts[errors.JVSRuntimePass] = 0
 	inst.Cnts[errors.JVSRuntimeFail] = 0
 	inst.Cnts[errors.JVSRuntimeWarning] = 0
 	inst.Cnts[errors.JVSRuntimeUnknown] = 0
+	inst.Cnts[errors.JVSRuntimeTimeout] = 0
+	inst.Cnts[errors.JVSRuntimeSkipped] = 0
+	inst.Cnts[errors.JVSRuntimeWaived] = 0
 	inst.keys = append(inst.keys, errors.JVSRuntimePass)
 	inst.keys = append(inst.keys, errors.JVSRuntimeFail)
 	inst.keys = append(inst.keys, errors.JVSRuntimeWarning)
 	inst.keys = append(inst.keys, errors.JVSRuntimeUnknown)
+	inst.keys = append(inst.keys, errors.JVSRuntimeTimeout)
+	inst.keys = append(inst.keys, errors.JVSRuntimeSkipped)
+	inst.keys = append(inst.keys, errors.JVSRuntimeWaived)
 	return inst
 }
 
@@ -40,8 +57,36 @@ func (s *StatusCnt) done() int {
 	return d
 }
 
+func (s *StatusCnt) failed() int {
+	f := 0
+	for k, v := range s.Cnts {
+		if errors.IsFailed(k) {
+			f += v
+		}
+	}
+	return f
+}
+
 func (s *StatusCnt) update(result *errors.JVSRuntimeResult) {
 	s.Cnts[result.Status]++
+	s.Time += result.Duration()
+	for _, r := range append(append([]*errors.JVSRuntimeResult{}, result.Attempts...), result) {
+		for _, p := range r.Phases {
+			if _, ok := s.Phases[p.Name]; !ok {
+				s.phaseKeys = append(s.phaseKeys, p.Name)
+			}
+			s.Phases[p.Name] += p.Duration()
+		}
+	}
+}
+
+//e.g. "prepare 1.2s, run 10.5s, check 0.1s"
+func (s *StatusCnt) PhasesString() string {
+	phases := make([]string, 0, len(s.phaseKeys))
+	for _, k := range s.phaseKeys {
+		phases = append(phases, k+" "+strconv.FormatFloat(s.Phases[k].Seconds(), 'f', 1, 64)+"s")
+	}
+	return strings.Join(phases, ", ")
 }
 
 func (s *StatusCnt) StatusString() string {
@@ -56,10 +101,23 @@ func (s *StatusCnt) StatusString() string {
 	return res
 }
 
+func (s *StatusCnt) ReportString() string {
+	res := s.name + "\t" + utils.Brown(strconv.Itoa(s.total)+"\t")
+	for _, k := range s.keys {
+		res += errors.StatusColor(k)(strconv.Itoa(s.Cnts[k])) + "\t"
+	}
+	res += utils.Brown(strconv.FormatFloat(s.Time.Seconds(), 'f', 1, 64) + "s\t" + s.PhasesString() + "\t")
+	return res
+}
+
 type statusReporter struct {
+	sync.Mutex
 	buildStatus *StatusCnt
 	testStatus  *StatusCnt
 	jobId       string
+	masterSeed  int
+	shard       string
+	queue       string
 	status      string
 }
 
@@ -71,39 +129,65 @@ func (r *statusReporter) Init(jobId string, totalBuild, totalTest int) {
 	r.buildStatus = newStatusCnt("BUILDS", totalBuild)
 	r.testStatus = newStatusCnt("TESTS", totalTest)
 	r.jobId = jobId
-	r.status = r.buildStatus.StatusString() + r.testStatus.StatusString()
+	r.queue = ""
+	r.refresh()
 }
 
 func (r *statusReporter) CollectBuildResult(result *errors.JVSRuntimeResult) {
+	r.Lock()
+	defer r.Unlock()
 	r.buildStatus.update(result)
-	r.status = r.buildStatus.StatusString() + r.testStatus.StatusString()
+	r.refresh()
 }
 
 func (r *statusReporter) CollectTestResult(result *errors.JVSRuntimeResult) {
+	r.Lock()
+	defer r.Unlock()
 	r.testStatus.update(result)
-	r.status = r.buildStatus.StatusString() + r.testStatus.StatusString()
+	r.refresh()
+}
+
+func (r *statusReporter) refresh() {
+	r.status = r.buildStatus.StatusString() + r.testStatus.StatusString() + utils.Brown(r.queue)
+}
+
+//queued and running jobs from scheduler, called concurrently with result collection
+func (r *statusReporter) updateQueue(queue string) {
+	r.Lock()
+	defer r.Unlock()
+	r.queue = queue
+	r.refresh()
+}
+
+//tests launched after Init by -duration
+func (r *statusReporter) addTotalTest(n int) {
+	r.Lock()
+	defer r.Unlock()
+	r.testStatus.total += n
+	r.refresh()
+}
+
+func (r *statusReporter) reportId() string {
+	id := "jobId " + r.jobId
+	if r.shard != "" {
+		id += " shard " + r.shard
+	}
+	return id
 }
 
 func (r *statusReporter) Report() {
 	const padding = 3
 	w := tabwriter.NewWriter(&stdout{}, 0, 0, padding, ' ', tabwriter.DiscardEmptyColumns|tabwriter.TabIndent|tabwriter.StripEscape|tabwriter.Debug)
-	fmt.Fprintln(w, utils.Brown("Jarvism Report for jobId "+r.jobId+":"))
-	fmt.Fprintln(w, " \t"+utils.Brown("TOTAL\t")+
-		errors.StatusColor(errors.JVSRuntimePass)(errors.StatusString(errors.JVSRuntimePass))+"\t"+
-		errors.StatusColor(errors.JVSRuntimeFail)(errors.StatusString(errors.JVSRuntimeFail))+"\t"+
-		errors.StatusColor(errors.JVSRuntimeWarning)(errors.StatusString(errors.JVSRuntimeWarning))+"\t"+
-		errors.StatusColor(errors.JVSRuntimeUnknown)(errors.StatusString(errors.JVSRuntimeUnknown))+"\t")
-	fmt.Fprintln(w, "BUILDS\t"+utils.Brown(strconv.Itoa(r.buildStatus.total)+"\t")+
-		errors.StatusColor(errors.JVSRuntimePass)(strconv.Itoa(r.buildStatus.Cnts[errors.JVSRuntimePass]))+"\t"+
-		errors.StatusColor(errors.JVSRuntimeFail)(strconv.Itoa(r.buildStatus.Cnts[errors.JVSRuntimeFail]))+"\t"+
-		errors.StatusColor(errors.JVSRuntimeWarning)(strconv.Itoa(r.buildStatus.Cnts[errors.JVSRuntimeWarning]))+"\t"+
-		errors.StatusColor(errors.JVSRuntimeUnknown)(strconv.Itoa(r.buildStatus.Cnts[errors.JVSRuntimeUnknown]))+"\t")
-	fmt.Fprintln(w, "TESTS\t"+utils.Brown(strconv.Itoa(r.testStatus.total)+"\t")+
-		errors.StatusColor(errors.JVSRuntimePass)(strconv.Itoa(r.testStatus.Cnts[errors.JVSRuntimePass]))+"\t"+
-		errors.StatusColor(errors.JVSRuntimeFail)(strconv.Itoa(r.testStatus.Cnts[errors.JVSRuntimeFail]))+"\t"+
-		errors.StatusColor(errors.JVSRuntimeWarning)(strconv.Itoa(r.testStatus.Cnts[errors.JVSRuntimeWarning]))+"\t"+
-		errors.StatusColor(errors.JVSRuntimeUnknown)(strconv.Itoa(r.testStatus.Cnts[errors.JVSRuntimeUnknown]))+"\t")
-	fmt.Fprintln(w, utils.Brown("Jarvism Report for jobId "+r.jobId+" Done!"))
+	fmt.Fprintln(w, utils.Brown("Jarvism Report for "+r.reportId()+"(master_seed "+strconv.Itoa(r.masterSeed)+"):"))
+	title := " \t" + utils.Brown("TOTAL\t")
+	for _, k := range r.testStatus.keys {
+		title += errors.StatusColor(k)(errors.StatusString(k)) + "\t"
+	}
+	title += utils.Brown("TIME\tPHASES\t")
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w, r.buildStatus.ReportString())
+	fmt.Fprintln(w, r.testStatus.ReportString())
+	fmt.Fprintln(w, utils.Brown("Jarvism Report for "+r.reportId()+" Done!"))
 	w.Flush()
 }
 
@@ -115,3 +199,8 @@ func GetBuildStatus() *StatusCnt {
 func GetTestStatus() *StatusCnt {
 	return status.testStatus
 }
+
+//failed builds and tests of last job, waived tests are not failures
+func Failed() bool {
+	return status.buildStatus != nil && status.buildStatus.failed()+status.testStatus.failed() > 0
+}
@@ -0,0 +1,41 @@
+package runtime
+
+import (
+	"github.com/shady831213/jarvism/core/errors"
+	"github.com/shady831213/jarvism/core/utils"
+	"strconv"
+	"sync/atomic"
+)
+
+//cancel the rest of job, running builds and tests are killed, not started ones are skipped
+func (r *runTime) stop(reason string) {
+	if atomic.CompareAndSwapInt32(&r.interrupted, 0, 1) {
+		r.stopReason.Store(reason)
+		Println(utils.Red("stop job " + r.runtimeId + ": " + reason))
+		r.cancel()
+	}
+}
+
+func (r *runTime) checkBuildBudget(result *errors.JVSRuntimeResult) {
+	if runTimeStopOnBuildFail && result.Status != errors.JVSRuntimePass && result.Status != errors.JVSRuntimeSkipped {
+		r.stop("build " + result.Name + " is " + errors.StatusString(result.Status) + "!")
+	}
+}
+
+func (r *runTime) checkTestBudget(result *errors.JVSRuntimeResult) {
+	if !errors.IsFailed(result.Status) {
+		return
+	}
+	r.failCnt++
+	if runTimeMaxFail >= 0 && r.failCnt > runTimeMaxFail {
+		r.stop(strconv.Itoa(r.failCnt) + " failed tests exceed max_fail " + strconv.Itoa(runTimeMaxFail) + "!")
+		return
+	}
+	total := r.totalTest
+	if r.launcher != nil {
+		total = r.launcher.launchedCnt()
+	}
+	if runTimeMaxFailRate >= 0 && float64(r.failCnt)*100 > runTimeMaxFailRate*float64(total) {
+		r.stop(strconv.Itoa(r.failCnt) + "/" + strconv.Itoa(total) + " failed tests exceed max_fail_rate " + strconv.FormatFloat(runTimeMaxFailRate, 'f', -1, 64) + "%!")
+	}
+}
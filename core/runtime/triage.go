@@ -0,0 +1,24 @@
+package runtime
+
+import (
+	"fmt"
+	"github.com/shady831213/jarvism/core/jobs"
+	"github.com/shady831213/jarvism/core/utils"
+	"strconv"
+	"text/tabwriter"
+)
+
+//failed tests grouped by signature, the most common first
+func printSignatures(jobId string, signatures []*jobs.SignatureRecord) {
+	if len(signatures) == 0 {
+		return
+	}
+	const padding = 3
+	w := tabwriter.NewWriter(&stdout{}, 0, 0, padding, ' ', tabwriter.DiscardEmptyColumns|tabwriter.TabIndent|tabwriter.StripEscape|tabwriter.Debug)
+	fmt.Fprintln(w, utils.Brown("Failure Signatures of jobId "+jobId+":"))
+	fmt.Fprintln(w, utils.Brown("COUNT\tSTATUS\tSIGNATURE\tEXAMPLE\tLOG\t"))
+	for _, s := range signatures {
+		fmt.Fprintln(w, strconv.Itoa(s.Count)+"\t"+s.Status+"\t"+s.Signature+"\t"+s.Example+"\t"+s.LogDir+"\t")
+	}
+	w.Flush()
+}
@@ -0,0 +1,57 @@
+package runtime
+
+import (
+	"fmt"
+	"github.com/shady831213/jarvism/core/errors"
+	"github.com/shady831213/jarvism/core/loader"
+	"github.com/shady831213/jarvism/core/utils"
+	"text/tabwriter"
+	"time"
+)
+
+//downgrade failed test matching a waiver to waived, original status and messages are kept in messages
+func (r *runTime) waive(result *errors.JVSRuntimeResult) *errors.JVSRuntimeResult {
+	if result.Identity == nil || !result.Identity.IsTest() {
+		return result
+	}
+	waiver := loader.GetJvsAstRoot().GetWaiver(result.Identity.Path(), result)
+	if waiver == nil {
+		return result
+	}
+	if r.waived == nil {
+		r.waived = make(map[*loader.Waiver]int)
+	}
+	r.waived[waiver]++
+	waived := errors.JVSRuntimeResultWaived(append([]string{errors.StatusString(result.Status) + " is waived by " + waiver.String()}, result.GetMsgs()...)...)
+	waived.Name = result.Name
+	waived.Identity = result.Identity
+	waived.StartTime, waived.EndTime = result.StartTime, result.EndTime
+	waived.Attempts = result.Attempts
+	waived.Phases = result.Phases
+	return waived
+}
+
+//expired waivers and waivers matching no test should be cleaned up
+func (r *runTime) printWaivers() {
+	const padding = 3
+	w := tabwriter.NewWriter(&stdout{}, 0, 0, padding, ' ', tabwriter.DiscardEmptyColumns|tabwriter.TabIndent|tabwriter.StripEscape|tabwriter.Debug)
+	title := false
+	now := time.Now()
+	for _, waiver := range loader.GetJvsAstRoot().Waivers {
+		state := ""
+		if waiver.Expired(now) {
+			state = "EXPIRED"
+		} else if r.waived[waiver] == 0 {
+			state = "UNUSED"
+		} else {
+			continue
+		}
+		if !title {
+			fmt.Fprintln(w, utils.Brown("Unused and Expired Waivers of jobId "+r.runtimeId+":"))
+			fmt.Fprintln(w, utils.Brown("STATE\tWAIVER\t"))
+			title = true
+		}
+		fmt.Fprintln(w, state+"\t"+waiver.String()+"\t")
+	}
+	w.Flush()
+}
@@ -57,6 +57,7 @@ package main
 import (
 	"fmt"
 	"github.com/shady831213/jarvism/cmd"
+	"github.com/shady831213/jarvism/cmd/base"
 	"github.com/shady831213/jarvism/core/utils"
 	"os"
 )
@@ -66,4 +67,5 @@ func main() {
 		fmt.Fprintln(os.Stderr, utils.Red(err.Error()))
 		os.Exit(2)
 	}
+	base.Exit()
 }
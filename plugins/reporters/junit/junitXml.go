@@ -21,21 +21,27 @@ type junitTestSuite struct {
 	XMLName    xml.Name        `xml:"testsuite"`
 	Tests      int             `xml:"tests,attr"`
 	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
 	Name       string          `xml:"name,attr"`
 	Time       string          `xml:"time,attr"`
 	Properties []junitProperty `xml:"properties>property,omitempty"`
 	TestCases  []junitTestCase
+	//wall-clock span of results in suite
+	startTime time.Time
+	endTime   time.Time
 }
 
 // junitTestCase is a single test case with its result.
 type junitTestCase struct {
-	XMLName     xml.Name          `xml:"testcase"`
-	Classname   string            `xml:"classname,attr"`
-	Name        string            `xml:"name,attr"`
-	Time        string            `xml:"time,attr"`
-	Status      string            `xml:"status,attr"`
-	SkipMessage *junitSkipMessage `xml:"skipped,omitempty"`
-	Failure     *junitFailure     `xml:"failure,omitempty"`
+	XMLName       xml.Name          `xml:"testcase"`
+	Classname     string            `xml:"classname,attr"`
+	Name          string            `xml:"name,attr"`
+	Time          string            `xml:"time,attr"`
+	Status        string            `xml:"status,attr"`
+	SkipMessage   *junitSkipMessage `xml:"skipped,omitempty"`
+	Failure       *junitFailure     `xml:"failure,omitempty"`
+	FlakyFailures []*junitFailure   `xml:"flakyFailure,omitempty"`
+	RerunFailures []*junitFailure   `xml:"rerunFailure,omitempty"`
 }
 
 // junitSkipMessage contains the reason why a testcase was skipped.
@@ -80,39 +86,78 @@ func initJunitXml(buildTotal, testTotal int) {
 	}
 }
 
-func updateBuild(result *errors.JVSRuntimeResult) {
-	buildSuite.TestCases = append(buildSuite.TestCases, updateResult(result))
-	if result.Status != errors.JVSRuntimePass {
-		buildSuite.Failures++
+func updateSuite(suite *junitTestSuite, result *errors.JVSRuntimeResult) {
+	suite.TestCases = append(suite.TestCases, updateResult(result))
+	if !result.StartTime.IsZero() && !result.EndTime.Before(result.StartTime) {
+		if suite.startTime.IsZero() || result.StartTime.Before(suite.startTime) {
+			suite.startTime = result.StartTime
+		}
+		if result.EndTime.After(suite.endTime) {
+			suite.endTime = result.EndTime
+		}
+		suite.Time = formatTime(suite.endTime.Sub(suite.startTime))
+	}
+	switch result.Status {
+	case errors.JVSRuntimePass:
+	case errors.JVSRuntimeSkipped, errors.JVSRuntimeWaived:
+		suite.Skipped++
+	default:
+		suite.Failures++
 	}
 }
 
+func updateBuild(result *errors.JVSRuntimeResult) {
+	updateSuite(buildSuite, result)
+}
+
 func updateTest(result *errors.JVSRuntimeResult) {
-	(*testSuite).TestCases = append(testSuite.TestCases, updateResult(result))
-	if result.Status != errors.JVSRuntimePass {
-		testSuite.Failures++
-	}
+	updateSuite(testSuite, result)
 }
 
 func updateResult(result *errors.JVSRuntimeResult) junitTestCase {
 	test := junitTestCase{
 		Classname: result.Name,
 		Name:      result.Name,
-		Time:      formatTime(0),
+		Time:      formatTime(result.Duration()),
 		Status:    errors.StatusString(result.Status),
 		Failure:   nil,
 	}
 
-	if result.Status != errors.JVSRuntimePass {
-		test.Failure = &junitFailure{
-			Message:  "Failed",
-			Type:     errors.StatusString(result.Status),
-			Contents: result.GetMsg(),
+	//waived failure is reported as skipped with the failure in message
+	if result.Status == errors.JVSRuntimeSkipped || result.Status == errors.JVSRuntimeWaived {
+		test.SkipMessage = &junitSkipMessage{
+			Message: result.GetMsg(),
 		}
+		return test
+	}
+
+	//failed attempts of retried test, flaky if it passes at last
+	for _, attempt := range result.Attempts {
+		if result.Status == errors.JVSRuntimePass {
+			test.FlakyFailures = append(test.FlakyFailures, newJunitFailure(attempt))
+		} else {
+			test.RerunFailures = append(test.RerunFailures, newJunitFailure(attempt))
+		}
+	}
+
+	if result.Status != errors.JVSRuntimePass {
+		test.Failure = newJunitFailure(result)
 	}
 	return test
 }
 
+func newJunitFailure(result *errors.JVSRuntimeResult) *junitFailure {
+	message := "Failed"
+	if result.Status == errors.JVSRuntimeTimeout {
+		message = "Timeout"
+	}
+	return &junitFailure{
+		Message:  message,
+		Type:     errors.StatusString(result.Status),
+		Contents: result.GetMsg(),
+	}
+}
+
 func writeReport(w io.Writer) error {
 	suites.Suites = append(suites.Suites, *buildSuite)
 	suites.Suites = append(suites.Suites, *testSuite)
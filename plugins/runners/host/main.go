@@ -35,6 +35,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 )
 
@@ -71,7 +72,7 @@ func (r *hostRunner) TestsRoot() string {
 }
 
 func (r *hostRunner) PrepareBuild(build *loader.AstBuild, cmdRunner loader.CmdRunner) *errors.JVSRuntimeResult {
-	_, buildName := loader.ParseBuildName(build.Name)
+	buildName := build.Identity.BuildName()
 	buildDir := path.Join(r.BuildsRoot(), buildName)
 	//create build dir
 	if err := os.MkdirAll(buildDir, os.ModePerm); err != nil {
@@ -110,7 +111,7 @@ func (r *hostRunner) PrepareBuild(build *loader.AstBuild, cmdRunner loader.CmdRu
 }
 
 func (r *hostRunner) Build(build *loader.AstBuild, cmdRunner loader.CmdRunner) *errors.JVSRuntimeResult {
-	_, buildName := loader.ParseBuildName(build.Name)
+	buildName := build.Identity.BuildName()
 	buildDir := path.Join(r.BuildsRoot(), buildName)
 	//create log file
 	logFile, err := os.Create(path.Join(buildDir, buildName+".log"))
@@ -128,8 +129,9 @@ func (r *hostRunner) Build(build *loader.AstBuild, cmdRunner loader.CmdRunner) *
 }
 
 func (r *hostRunner) PrepareTest(testCase *loader.AstTestCase, cmdRunner loader.CmdRunner) *errors.JVSRuntimeResult {
-	_, buildName, testName, seed, groupsName := loader.ParseTestName(testCase.Name)
-	testDir := path.Join(r.TestsRoot(), path.Join(groupsName...), buildName+"__"+testName, seed)
+	id := testCase.Identity
+	buildName, testName, seed := id.BuildName(), id.Test, strconv.Itoa(id.Seed)
+	testDir := path.Join(r.TestsRoot(), path.Join(id.Groups...), buildName+"__"+testName, seed)
 	buildDir := path.Join(r.BuildsRoot(), buildName)
 	//create test dir
 	if err := os.MkdirAll(testDir, os.ModePerm); err != nil {
@@ -159,8 +161,9 @@ func (r *hostRunner) PrepareTest(testCase *loader.AstTestCase, cmdRunner loader.
 }
 
 func (r *hostRunner) RunTest(testCase *loader.AstTestCase, cmdRunner loader.CmdRunner) *errors.JVSRuntimeResult {
-	_, buildName, testName, seed, groupsName := loader.ParseTestName(testCase.Name)
-	testDir := path.Join(r.TestsRoot(), path.Join(groupsName...), buildName+"__"+testName, seed)
+	id := testCase.Identity
+	buildName, testName, seed := id.BuildName(), id.Test, strconv.Itoa(id.Seed)
+	testDir := path.Join(r.TestsRoot(), path.Join(id.Groups...), buildName+"__"+testName, seed)
 	//create log file
 	logFile, err := os.Create(path.Join(testDir, buildName+"__"+testName+"__"+seed+".log"))
 	defer logFile.Close()